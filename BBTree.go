@@ -2,6 +2,7 @@ package chipmunk
 
 import (
 	//"log"
+	"sort"
 	"time"
 )
 
@@ -16,16 +17,25 @@ func (set HashSet) Each(fnc HashSetIterator) {
 }
 
 type BBTree struct {
-	SpatialIndexClass
 	SpatialIndex *SpatialIndex
 
 	leaves HashSet
-	root   *Node
+	// The leaves in insertion order, iterated instead of the map so the
+	// order of the pairs and of the collisions is deterministic.
+	leafList []*Node
+	root     *Node
 
 	pairBuffer []*Pair
 	nodeBuffer []*Node
 
 	stamp time.Duration
+
+	// Fraction of the size of an object its leaf box is grown by on each side. Defaults to 0.1.
+	MarginCoef float32
+	// Seconds of motion the leaf box of a moving object is stretched by along its velocity.
+	// Larger values make fast objects leave their box and get reinserted less often,
+	// at the cost of looser boxes and more pairs to test. Defaults to 0.1.
+	VelocityCoef float32
 }
 
 type Children struct {
@@ -35,6 +45,8 @@ type Children struct {
 type Leaf struct {
 	stamp time.Duration
 	pairs *Pair
+	// Position of the leaf in BBTree.leafList.
+	index int
 }
 
 type node struct {
@@ -84,6 +96,8 @@ type Pair struct {
 func NewBBTree(staticIndex *SpatialIndex) *SpatialIndex {
 	tree := &BBTree{}
 	tree.leaves = make(map[HashValue]*Node)
+	tree.MarginCoef = 0.1
+	tree.VelocityCoef = 0.1
 
 	tree.SpatialIndex = NewSpartialIndex(tree, staticIndex)
 	tree.pairBuffer = make([]*Pair, 0)
@@ -95,6 +109,19 @@ func (tree *BBTree) Count() int {
 	return len(tree.leaves)
 }
 
+func (tree *BBTree) Contains(obj Indexable) bool {
+	_, ok := tree.leaves[obj.Hash()]
+	return ok
+}
+
+func (tree *BBTree) Destroy() {
+	tree.leaves = make(map[HashValue]*Node)
+	tree.leafList = nil
+	tree.root = nil
+	tree.pairBuffer = nil
+	tree.nodeBuffer = nil
+}
+
 func (tree *BBTree) NewLeaf(obj Indexable) *Node {
 	node := tree.NodeFromPool()
 	node.obj = obj
@@ -117,7 +144,7 @@ func (tree *BBTree) GetBB(obj Indexable) AABB {
 	v, ok := obj.Velocity()
 	if ok {
 		bb := obj.AABB()
-		coef := float32(0.1)
+		coef := tree.MarginCoef
 
 		l := bb.Lower.X
 		b := bb.Lower.Y
@@ -127,7 +154,7 @@ func (tree *BBTree) GetBB(obj Indexable) AABB {
 		x := (r - l) * coef
 		y := (t - b) * coef
 
-		v = Mult(v, 0.1)
+		v = Mult(v, tree.VelocityCoef)
 
 		return NewAABB(l+FMin(-x, v.X), b+FMin(-y, v.Y), r+FMax(x, v.X), t+FMax(y, v.Y))
 	}
@@ -229,6 +256,8 @@ func (tree *BBTree) Insert(obj Indexable) {
 	leaf := tree.NewLeaf(obj)
 
 	tree.leaves[obj.Hash()] = leaf
+	leaf.index = len(tree.leafList)
+	tree.leafList = append(tree.leafList, leaf)
 
 	root := tree.root
 	tree.root = tree.SubtreeInsert(root, leaf)
@@ -239,6 +268,114 @@ func (tree *BBTree) Insert(obj Indexable) {
 	tree.IncrementStamp()
 }
 
+// Inserts all objects and builds the tree once with them instead of inserting them one by one.
+func (tree *BBTree) insertBatch(objs []Indexable) {
+	leaves := make([]*Node, 0, len(objs))
+	for _, obj := range objs {
+		leaf := tree.NewLeaf(obj)
+
+		tree.leaves[obj.Hash()] = leaf
+		leaf.index = len(tree.leafList)
+		tree.leafList = append(tree.leafList, leaf)
+		leaves = append(leaves, leaf)
+	}
+	tree.Optimize()
+
+	for _, leaf := range leaves {
+		leaf.stamp = tree.GetMasterTree().Stamp()
+
+		tree.LeafAddPairs(leaf)
+		tree.IncrementStamp()
+	}
+}
+
+// Rebuilds the tree top-down from its leaves, splitting them at the median of their longest axis.
+// Inserting objects one by one can give a poorly balanced tree, call it after adding a lot of static shapes.
+func (tree *BBTree) Optimize() {
+	if len(tree.leafList) == 0 {
+		return
+	}
+
+	nodes := make([]*Node, len(tree.leafList))
+	copy(nodes, tree.leafList)
+
+	if tree.root != nil {
+		tree.SubtreeRecycle(tree.root)
+	}
+	tree.root = tree.partitionNodes(nodes)
+	tree.root.parent = nil
+}
+
+// Recycles the inner nodes of the subtree, the leaves are kept.
+func (tree *BBTree) SubtreeRecycle(node *Node) {
+	if !node.IsLeaf() {
+		tree.SubtreeRecycle(node.A)
+		tree.SubtreeRecycle(node.B)
+		tree.NodeRecycle(node)
+	}
+}
+
+func (tree *BBTree) partitionNodes(nodes []*Node) *Node {
+	count := len(nodes)
+	if count == 1 {
+		return nodes[0]
+	} else if count == 2 {
+		return tree.NodeNew(nodes[0], nodes[1])
+	}
+
+	// Find the AABB for these nodes
+	bb := nodes[0].bb
+	for _, node := range nodes[1:] {
+		bb = CombinePtr(&bb, &node.bb)
+	}
+
+	// Split it on it's longest axis
+	splitWidth := bb.Upper.X-bb.Lower.X > bb.Upper.Y-bb.Lower.Y
+
+	// Sort the bounds and use the median as the splitting point
+	bounds := make([]float32, count*2)
+	for i, node := range nodes {
+		if splitWidth {
+			bounds[2*i], bounds[2*i+1] = node.bb.Lower.X, node.bb.Upper.X
+		} else {
+			bounds[2*i], bounds[2*i+1] = node.bb.Lower.Y, node.bb.Upper.Y
+		}
+	}
+	sort.Slice(bounds, func(i, j int) bool { return bounds[i] < bounds[j] })
+	split := (bounds[count-1] + bounds[count]) * 0.5
+
+	// Generate the child BBs
+	a, b := bb, bb
+	if splitWidth {
+		a.Upper.X, b.Lower.X = split, split
+	} else {
+		a.Upper.Y, b.Lower.Y = split, split
+	}
+
+	// Partition the nodes
+	right := count
+	for left := 0; left < right; {
+		node := nodes[left]
+		if MergedAreaPtr(&node.bb, &b) < MergedAreaPtr(&node.bb, &a) {
+			right--
+			nodes[left], nodes[right] = nodes[right], node
+		} else {
+			left++
+		}
+	}
+
+	if right == count {
+		// All the nodes went to the same side, fall back to inserting them.
+		var node *Node
+		for _, leaf := range nodes {
+			node = tree.SubtreeInsert(node, leaf)
+		}
+		return node
+	}
+
+	return tree.NodeNew(tree.partitionNodes(nodes[:right]), tree.partitionNodes(nodes[right:]))
+}
+
 func (tree *BBTree) PairInsert(a, b *Node) {
 	nextA := a.pairs
 	nextB := b.pairs
@@ -337,6 +474,13 @@ func (tree *BBTree) Remove(obj Indexable) {
 		return
 	}
 
+	last := len(tree.leafList) - 1
+	moved := tree.leafList[last]
+	tree.leafList[leaf.index] = moved
+	moved.index = leaf.index
+	tree.leafList[last] = nil
+	tree.leafList = tree.leafList[:last]
+
 	tree.root = tree.SubtreeRemove(tree.root, leaf)
 	tree.PairsClear(leaf)
 	tree.NodeRecycle(leaf)
@@ -419,8 +563,35 @@ func LeafUpdate(leaf *Node, tree *BBTree) bool {
 	return false
 }
 
+// Updates the leaf of the object if it moved out of its box.
+func (tree *BBTree) ReindexObject(obj Indexable) {
+	leaf := tree.leaves[obj.Hash()]
+	if leaf == nil {
+		return
+	}
+	tree.reindexLeaf(leaf)
+}
+
+// Updates the leaves of the objects that moved out of their box.
+// Unlike ReindexQuery() it also keeps the pairs of a static tree with the dynamic tree,
+// so it's the one to use after moving static shapes.
+func (tree *BBTree) Reindex() {
+	for _, leaf := range tree.leafList {
+		tree.reindexLeaf(leaf)
+	}
+}
+
+func (tree *BBTree) reindexLeaf(leaf *Node) {
+	if LeafUpdate(leaf, tree) {
+		tree.LeafAddPairs(leaf)
+	}
+	tree.IncrementStamp()
+}
+
 func (tree *BBTree) Each(fnc HashSetIterator) {
-	tree.leaves.Each(fnc)
+	for _, leaf := range tree.leafList {
+		fnc(leaf)
+	}
 }
 
 func (tree *BBTree) ReindexQuery(fnc SpatialIndexQueryFunc) {
@@ -429,7 +600,7 @@ func (tree *BBTree) ReindexQuery(fnc SpatialIndexQueryFunc) {
 	}
 
 	// LeafUpdate() may modify tree->root. Don't cache it.
-	for _, node := range tree.leaves {
+	for _, node := range tree.leafList {
 		LeafUpdate(node, tree)
 	}
 
@@ -457,7 +628,9 @@ func (tree *BBTree) Query(obj Indexable, aabb AABB, fnc SpatialIndexQueryFunc) {
 func SubtreeQuery(subtree *Node, obj Indexable, bb AABB, fnc SpatialIndexQueryFunc) {
 	if TestOverlap(subtree.bb, bb) {
 		if subtree.IsLeaf() {
-			fnc(obj, subtree.obj)
+			if subtree.obj != obj {
+				fnc(obj, subtree.obj)
+			}
 		} else {
 			SubtreeQuery(subtree.A, obj, bb, fnc)
 			SubtreeQuery(subtree.B, obj, bb, fnc)
@@ -465,6 +638,40 @@ func SubtreeQuery(subtree *Node, obj Indexable, bb AABB, fnc SpatialIndexQueryFu
 	}
 }
 
+func (tree *BBTree) SegmentQuery(obj Indexable, a, b Vect, t_exit float32, fnc SpatialIndexSegmentQueryFunc) {
+	if tree.root != nil {
+		SubtreeSegmentQuery(tree.root, obj, a, b, t_exit, fnc)
+	}
+}
+
+// Walks the subtree in the order the segment hits the nodes and skips the nodes that are hit after t_exit.
+func SubtreeSegmentQuery(subtree *Node, obj Indexable, a, b Vect, t_exit float32, fnc SpatialIndexSegmentQueryFunc) float32 {
+	if subtree.IsLeaf() {
+		return fnc(obj, subtree.obj)
+	}
+
+	t_a := subtree.A.bb.SegmentQuery(a, b)
+	t_b := subtree.B.bb.SegmentQuery(a, b)
+
+	if t_a < t_b {
+		if t_a < t_exit {
+			t_exit = FMin(t_exit, SubtreeSegmentQuery(subtree.A, obj, a, b, t_exit, fnc))
+		}
+		if t_b < t_exit {
+			t_exit = FMin(t_exit, SubtreeSegmentQuery(subtree.B, obj, a, b, t_exit, fnc))
+		}
+	} else {
+		if t_b < t_exit {
+			t_exit = FMin(t_exit, SubtreeSegmentQuery(subtree.B, obj, a, b, t_exit, fnc))
+		}
+		if t_a < t_exit {
+			t_exit = FMin(t_exit, SubtreeSegmentQuery(subtree.A, obj, a, b, t_exit, fnc))
+		}
+	}
+
+	return t_exit
+}
+
 type MarkContext struct {
 	tree       *BBTree
 	staticRoot *Node
@@ -529,3 +736,103 @@ func (context *MarkContext) MarkLeaf(leaf *Node) {
 		//fmt.Println(i)
 	}
 }
+
+// The saved state of a BBTree and of its static tree.
+// Both trees are saved together as the pairs are threaded through the leaves of both.
+type bbTreeSnapshot struct {
+	root, staticRoot         *Node
+	leafList, staticLeafList []*Node
+	stamp, staticStamp       time.Duration
+}
+
+func (tree *BBTree) snapshot() interface{} {
+	snapshot := &bbTreeSnapshot{root: tree.root, leafList: tree.leafList, stamp: tree.stamp}
+	if staticTree := GetTree(tree.SpatialIndex.staticIndex); staticTree != nil {
+		snapshot.staticRoot = staticTree.root
+		snapshot.staticLeafList = staticTree.leafList
+		snapshot.staticStamp = staticTree.stamp
+	}
+	return snapshot.copy()
+}
+
+func (tree *BBTree) restore(state interface{}) {
+	// Copy the nodes again so the snapshot can be restored more than once.
+	snapshot := state.(*bbTreeSnapshot).copy()
+
+	tree.setNodes(snapshot.root, snapshot.leafList, snapshot.stamp)
+	if staticTree := GetTree(tree.SpatialIndex.staticIndex); staticTree != nil {
+		staticTree.setNodes(snapshot.staticRoot, snapshot.staticLeafList, snapshot.staticStamp)
+	}
+}
+
+func (tree *BBTree) setNodes(root *Node, leafList []*Node, stamp time.Duration) {
+	tree.root = root
+	tree.leafList = leafList
+	tree.stamp = stamp
+
+	tree.leaves = make(HashSet, len(leafList))
+	for _, leaf := range leafList {
+		tree.leaves[leaf.obj.Hash()] = leaf
+	}
+}
+
+// Returns a copy of the nodes of both trees and of the pairs between their leaves.
+func (snapshot *bbTreeSnapshot) copy() *bbTreeSnapshot {
+	nodes := make(map[*Node]*Node)
+	pairs := make(map[*Pair]*Pair)
+
+	var allocNodes func(node *Node)
+	allocNodes = func(node *Node) {
+		if node == nil {
+			return
+		}
+		nodes[node] = &Node{}
+		if !node.IsLeaf() {
+			allocNodes(node.A)
+			allocNodes(node.B)
+		}
+	}
+
+	var copyPair func(pair *Pair) *Pair
+	copyPair = func(pair *Pair) *Pair {
+		if pair == nil {
+			return nil
+		}
+		if c, ok := pairs[pair]; ok {
+			return c
+		}
+		c := &Pair{}
+		pairs[pair] = c
+		c.a = Thread{copyPair(pair.a.prev), nodes[pair.a.leaf], copyPair(pair.a.next)}
+		c.b = Thread{copyPair(pair.b.prev), nodes[pair.b.leaf], copyPair(pair.b.next)}
+		return c
+	}
+
+	copyList := func(leafList []*Node) []*Node {
+		list := make([]*Node, len(leafList))
+		for i, leaf := range leafList {
+			list[i] = nodes[leaf]
+		}
+		return list
+	}
+
+	allocNodes(snapshot.root)
+	allocNodes(snapshot.staticRoot)
+
+	for node, c := range nodes {
+		*c = *node
+		c.parent = nodes[node.parent]
+		c.A = nodes[node.A]
+		c.B = nodes[node.B]
+		c.pairs = copyPair(node.pairs)
+	}
+
+	return &bbTreeSnapshot{
+		root:           nodes[snapshot.root],
+		staticRoot:     nodes[snapshot.staticRoot],
+		leafList:       copyList(snapshot.leafList),
+		staticLeafList: copyList(snapshot.staticLeafList),
+		stamp:          snapshot.stamp,
+		staticStamp:    snapshot.staticStamp,
+	}
+}
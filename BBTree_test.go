@@ -0,0 +1,212 @@
+package chipmunk
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// Creates a tree of small circles moving in random directions inside a square of the given size.
+func newMovingTree(count int, size float32) (*BBTree, []*Body) {
+	tree := GetTree(NewBBTree(nil).SpatialIndexClass)
+	rng := rand.New(rand.NewSource(1))
+
+	bodies := make([]*Body, count)
+	for i := range bodies {
+		body := NewBody(1, 1)
+		shape := NewCircle(Vector_Zero, 2)
+		body.AddShape(shape)
+		body.SetPosition(Vect{rng.Float32() * size, rng.Float32() * size})
+		body.SetVelocity(rng.Float32()*400-200, rng.Float32()*400-200)
+		shape.Update()
+
+		tree.Insert(shape)
+		bodies[i] = body
+	}
+	return tree, bodies
+}
+
+// Moves the bodies by one step, bouncing them off the sides of the square.
+func moveBodies(bodies []*Body, size, dt float32) {
+	for _, body := range bodies {
+		p := Add(body.p, Mult(body.v, dt))
+		if p.X < 0 || p.X > size {
+			body.v.X = -body.v.X
+		}
+		if p.Y < 0 || p.Y > size {
+			body.v.Y = -body.v.Y
+		}
+		body.p = p
+		body.UpdateShapes()
+	}
+}
+
+// Reindexes the tree and returns how many leaves had to be reinserted.
+func reindexCount(tree *BBTree) int {
+	stamp := tree.GetMasterTreeStamp()
+	tree.ReindexQuery(func(a, b Indexable) {})
+
+	reinserted := 0
+	for _, leaf := range tree.leafList {
+		if leaf.stamp == stamp {
+			reinserted++
+		}
+	}
+	return reinserted
+}
+
+func BenchmarkBBTreeReindexVelocity(b *testing.B) {
+	const size = 1000
+	const dt = 1.0 / 60.0
+
+	for _, coef := range []float32{0, 0.05, 0.1, 0.2} {
+		b.Run(fmt.Sprintf("VelocityCoef=%v", coef), func(b *testing.B) {
+			tree, bodies := newMovingTree(1000, size)
+			tree.VelocityCoef = coef
+			for _, leaf := range tree.leafList {
+				leaf.bb = tree.GetBB(leaf.obj)
+			}
+
+			reinserted := 0
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				moveBodies(bodies, size, dt)
+				reinserted += reindexCount(tree)
+			}
+			b.ReportMetric(float64(reinserted)/float64(b.N), "reinserts/op")
+		})
+	}
+}
+
+func BenchmarkSpaceStepMovingBodies(b *testing.B) {
+	for _, coef := range []float32{0, 0.1} {
+		b.Run(fmt.Sprintf("VelocityCoef=%v", coef), func(b *testing.B) {
+			space := NewSpace()
+			GetTree(space.activeShapes.SpatialIndexClass).VelocityCoef = coef
+
+			rng := rand.New(rand.NewSource(1))
+			for i := 0; i < 500; i++ {
+				body := NewBody(1, 1)
+				body.AddShape(NewCircle(Vector_Zero, 2))
+				body.SetPosition(Vect{rng.Float32() * 2000, rng.Float32() * 2000})
+				body.SetVelocity(rng.Float32()*400-200, rng.Float32()*400-200)
+				space.AddBody(body)
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				space.Step(1.0 / 60.0)
+			}
+		})
+	}
+}
+
+// Creates the static segments of a long level, in the order a level loader would add them.
+func newLevelSegments(count int) []*Shape {
+	rng := rand.New(rand.NewSource(1))
+	body := NewBodyStatic()
+
+	shapes := make([]*Shape, count)
+	a := Vect{0, 0}
+	for i := range shapes {
+		b := Vect{a.X + 10, a.Y + rng.Float32()*10 - 5}
+		shape := NewSegment(a, b, 1)
+		body.AddShape(shape)
+		shape.Update()
+		shapes[i] = shape
+		a = b
+	}
+	return shapes
+}
+
+func subtreeDepth(node *Node) int {
+	if node == nil {
+		return 0
+	}
+	if node.IsLeaf() {
+		return 1
+	}
+	a, b := subtreeDepth(node.A), subtreeDepth(node.B)
+	if a > b {
+		return a + 1
+	}
+	return b + 1
+}
+
+func TestBBTreeOptimize(t *testing.T) {
+	shapes := newLevelSegments(1000)
+	tree := GetTree(NewBBTree(nil).SpatialIndexClass)
+	for _, shape := range shapes {
+		tree.Insert(shape)
+	}
+
+	before := subtreeDepth(tree.root)
+	tree.Optimize()
+	after := subtreeDepth(tree.root)
+	if after >= before {
+		t.Fatalf("depth is %d after optimizing, was %d", after, before)
+	}
+	if tree.root.parent != nil {
+		t.Fatal("root has a parent")
+	}
+
+	rng := rand.New(rand.NewSource(2))
+	for i := 0; i < 100; i++ {
+		aabb := randomAABB(rng, 10000)
+		found := make(map[*Shape]int)
+		tree.Query(nil, aabb, func(a, b Indexable) {
+			found[b.Shape()]++
+		})
+		for _, shape := range shapes {
+			if found[shape] > 1 || (TestOverlap(shape.BB, aabb) && found[shape] == 0) {
+				t.Fatalf("shape at %v found %d times by a query of %v", shape.BB, found[shape], aabb)
+			}
+		}
+	}
+}
+
+// Queries small boxes around random segments of the level.
+func benchmarkLevelQuery(b *testing.B, tree *BBTree, shapes []*Shape) {
+	rng := rand.New(rand.NewSource(2))
+	queries := make([]AABB, 1000)
+	for i := range queries {
+		c := shapes[rng.Intn(len(shapes))].BB.Center()
+		queries[i] = NewAABB(c.X-10, c.Y-10, c.X+10, c.Y+10)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tree.Query(nil, queries[i%len(queries)], func(a, b Indexable) {})
+	}
+	b.ReportMetric(float64(subtreeDepth(tree.root)), "depth")
+}
+
+func BenchmarkBBTreeLevelQuery(b *testing.B) {
+	shapes := newLevelSegments(5000)
+
+	b.Run("Insert", func(b *testing.B) {
+		tree := GetTree(NewBBTree(nil).SpatialIndexClass)
+		for _, shape := range shapes {
+			tree.Insert(shape)
+		}
+		benchmarkLevelQuery(b, tree, shapes)
+	})
+
+	b.Run("Optimize", func(b *testing.B) {
+		tree := GetTree(NewBBTree(nil).SpatialIndexClass)
+		for _, shape := range shapes {
+			tree.Insert(shape)
+		}
+		tree.Optimize()
+		benchmarkLevelQuery(b, tree, shapes)
+	})
+
+	b.Run("AddShapes", func(b *testing.B) {
+		space := NewSpace()
+		level := newLevelSegments(5000)
+		if err := space.AddShapes(level...); err != nil {
+			b.Fatal(err)
+		}
+		benchmarkLevelQuery(b, GetTree(space.staticShapes.SpatialIndexClass), level)
+	})
+}
@@ -1,5 +1,9 @@
 package chipmunk
 
+import (
+	"math"
+)
+
 //axis aligned bounding box.
 type AABB struct {
 	Lower, //l b
@@ -104,6 +108,47 @@ func Proximity(a, b AABB) float32 {
 	return FAbs(a.Lower.X+a.Upper.X-b.Lower.X-b.Upper.X) + FAbs(a.Lower.Y+a.Upper.Y-b.Lower.Y-b.Upper.Y)
 }
 
+// Returns the fraction along the segment from a to b where it first hits the bounding box,
+// or Inf if it misses it.
+func (aabb *AABB) SegmentQuery(a, b Vect) float32 {
+	inf := float32(math.Inf(1))
+
+	idx := 1 / (b.X - a.X)
+	tx1 := (aabb.Lower.X - a.X) * idx
+	if aabb.Lower.X == a.X {
+		tx1 = -inf
+	}
+	tx2 := (aabb.Upper.X - a.X) * idx
+	if aabb.Upper.X == a.X {
+		tx2 = inf
+	}
+	txmin := FMin(tx1, tx2)
+	txmax := FMax(tx1, tx2)
+
+	idy := 1 / (b.Y - a.Y)
+	ty1 := (aabb.Lower.Y - a.Y) * idy
+	if aabb.Lower.Y == a.Y {
+		ty1 = -inf
+	}
+	ty2 := (aabb.Upper.Y - a.Y) * idy
+	if aabb.Upper.Y == a.Y {
+		ty2 = inf
+	}
+	tymin := FMin(ty1, ty2)
+	tymax := FMax(ty1, ty2)
+
+	if tymin <= txmax && txmin <= tymax {
+		min := FMax(txmin, tymin)
+		max := FMin(txmax, tymax)
+
+		if 0 <= max && min <= 1 {
+			return FMax(min, 0)
+		}
+	}
+
+	return inf
+}
+
 func TestOverlap2(a, b AABB) bool {
 
 	d1 := Sub(b.Lower, a.Upper)
@@ -13,6 +13,29 @@ type ArbiterEdge struct {
 	Other      *Body
 }
 
+// Returns the collision normal pointing away from the body that owns the edge towards Other.
+func (edge *ArbiterEdge) Normal() Vect {
+	arb := edge.Arbiter
+	if len(arb.Contacts) == 0 {
+		return Vector_Zero
+	}
+
+	n := arb.Contacts[0].n
+	if edge == arb.nodeB {
+		return Mult(n, -1)
+	}
+	return n
+}
+
+// Returns the impulse including friction that was applied to the body that owns the edge during the last step.
+func (edge *ArbiterEdge) Impulse() Vect {
+	j := edge.Arbiter.TotalImpulseWithFriction()
+	if edge == edge.Arbiter.nodeB {
+		return Mult(j, -1)
+	}
+	return j
+}
+
 type arbiterState int
 
 const (
@@ -20,6 +43,8 @@ const (
 	arbiterStateNormal
 	arbiterStateIgnore
 	arbiterStateCached
+	// The arbiter's shapes or bodies were removed from the space.
+	arbiterStateInvalidated
 )
 
 // The maximum number of ContactPoints a single Arbiter can have.
@@ -34,7 +59,8 @@ type Arbiter struct {
 	// The number of contact points.
 	NumContacts int
 
-	//nodeA, nodeB *ArbiterEdge
+	// Edges of the arbiter in the arbiter lists of BodyA and BodyB.
+	nodeA, nodeB *ArbiterEdge
 
 	/// Calculated value to use for the elasticity coefficient.
 	/// Override in a pre-solve collision handler for custom behavior.
@@ -46,12 +72,53 @@ type Arbiter struct {
 	/// Override in a pre-solve collision handler for custom behavior.
 	Surface_vr Vect
 
+	// The collision handler of the shapes, or nil.
+	handler *CollisionHandler
+	// True if the shapes are in the opposite order of the handler's types.
+	swapped bool
+
 	state arbiterState
 	stamp time.Duration
 }
 
 func newArbiter() *Arbiter {
-	return new(Arbiter)
+	arb := new(Arbiter)
+	arb.nodeA = &ArbiterEdge{Arbiter: arb}
+	arb.nodeB = &ArbiterEdge{Arbiter: arb}
+	return arb
+}
+
+// Returns the edge of the arbiter that is threaded into the arbiter list of body.
+func (arb *Arbiter) edgeFor(body *Body) *ArbiterEdge {
+	if arb.BodyA == body {
+		return arb.nodeA
+	}
+	return arb.nodeB
+}
+
+func (arb *Arbiter) unthreadHelper(body *Body) {
+	edge := arb.edgeFor(body)
+	prev, next := edge.Prev, edge.Next
+
+	if prev != nil {
+		prev.Next = next
+	} else if body.arbiterList == edge {
+		// IFF prev is nil and body.arbiterList == edge, is edge at the head of the list.
+		body.arbiterList = next
+	}
+
+	if next != nil {
+		next.Prev = prev
+	}
+
+	edge.Prev = nil
+	edge.Next = nil
+}
+
+// Removes the arbiter from the arbiter lists of both bodies.
+func (arb *Arbiter) unthread() {
+	arb.unthreadHelper(arb.BodyA)
+	arb.unthreadHelper(arb.BodyB)
 }
 
 func (arb *Arbiter) destroy() {
@@ -98,6 +165,140 @@ func (arb *Arbiter) update(a, b *Shape, contacts []*Contact, numContacts int) {
 	}
 }
 
+// Returns the sum of the normal impulses applied to BodyA during the last step.
+func (arb *Arbiter) TotalImpulse() Vect {
+	var sum Vect
+	for _, con := range arb.Contacts {
+		sum.Add(Mult(con.n, con.jnAcc))
+	}
+	return Mult(sum, -1)
+}
+
+// Returns the sum of the impulses including friction applied to BodyA during the last step.
+func (arb *Arbiter) TotalImpulseWithFriction() Vect {
+	var sum Vect
+	for _, con := range arb.Contacts {
+		sum.Add(RotateVect(con.n, Rotation{con.jnAcc, con.jtAcc}))
+	}
+	return Mult(sum, -1)
+}
+
+// Returns the colliding shapes in the order of the collision types of the arbiter's CollisionHandler.
+func (arb *Arbiter) Shapes() (a, b *Shape) {
+	if arb.swapped {
+		return arb.ShapeB, arb.ShapeA
+	}
+	return arb.ShapeA, arb.ShapeB
+}
+
+// Returns the colliding bodies in the order of the collision types of the arbiter's CollisionHandler.
+func (arb *Arbiter) Bodies() (a, b *Body) {
+	if arb.swapped {
+		return arb.BodyB, arb.BodyA
+	}
+	return arb.BodyA, arb.BodyB
+}
+
+// Returns the collision normal pointing from the first shape returned by Shapes to the second one,
+// or the zero vector if there are no contacts.
+func (arb *Arbiter) Normal() Vect {
+	if arb.NumContacts == 0 {
+		return Vector_Zero
+	}
+	n := arb.Contacts[0].n
+	if arb.swapped {
+		return Mult(n, -1)
+	}
+	return n
+}
+
+// Returns the shape of body first and the shape it collides with second,
+// together with the collision normal pointing away from body.
+// If body is not part of the collision, the shapes are returned in the order of Shapes.
+func (arb *Arbiter) ShapesFor(body *Body) (mine, other *Shape, n Vect) {
+	if arb.BodyB == body && arb.BodyA != body {
+		return arb.ShapeB, arb.ShapeA, arb.nodeB.Normal()
+	}
+	if arb.BodyA == body {
+		return arb.ShapeA, arb.ShapeB, arb.nodeA.Normal()
+	}
+	mine, other = arb.Shapes()
+	return mine, other, arb.Normal()
+}
+
+// Returns true during the first step the shapes touch, that is in the begin callback
+// and the first pre-solve and post-solve callbacks.
+func (arb *Arbiter) IsFirstContact() bool {
+	return arb.state == arbiterStateFirstColl
+}
+
+// Returns true in a separate callback that was caused by removing one of the shapes or bodies from the space
+// rather than by the shapes moving apart.
+func (arb *Arbiter) IsRemoval() bool {
+	return arb.state == arbiterStateInvalidated
+}
+
+// Calls the begin callbacks of the handler and then of the bodies, BodyB first.
+// Stops at the first callback that returns false, the collision is then ignored.
+func (arb *Arbiter) callBegin(space *Space) bool {
+	ignore := false
+	if arb.handler != nil && arb.handler.Begin != nil {
+		ignore = !arb.handler.Begin(arb, space)
+	}
+	if arb.BodyB.CallbackHandler != nil {
+		ignore = ignore || !arb.BodyB.CallbackHandler.CollisionEnter(arb)
+	}
+	if arb.BodyA.CallbackHandler != nil {
+		ignore = ignore || !arb.BodyA.CallbackHandler.CollisionEnter(arb)
+	}
+	return !ignore
+}
+
+// Calls the pre-solve callbacks of the handler and the bodies.
+// Returns false if the collision should be ignored for this step.
+func (arb *Arbiter) callPreSolve(space *Space) bool {
+	handlerResult := true
+	if arb.handler != nil && arb.handler.PreSolve != nil {
+		handlerResult = arb.handler.PreSolve(arb, space)
+	}
+
+	preSolveResult := true
+	if arb.BodyA.CallbackHandler != nil {
+		preSolveResult = arb.BodyA.CallbackHandler.CollisionPreSolve(arb)
+	}
+	if arb.BodyB.CallbackHandler != nil {
+		preSolveResult = preSolveResult || arb.BodyB.CallbackHandler.CollisionPreSolve(arb)
+	}
+	return handlerResult && preSolveResult
+}
+
+// Calls the post-solve callbacks of the handler and the bodies.
+func (arb *Arbiter) callPostSolve(space *Space) {
+	if arb.handler != nil && arb.handler.PostSolve != nil {
+		arb.handler.PostSolve(arb, space)
+	}
+	if arb.BodyA.CallbackHandler != nil {
+		arb.BodyA.CallbackHandler.CollisionPostSolve(arb)
+	}
+	if arb.BodyB.CallbackHandler != nil {
+		arb.BodyB.CallbackHandler.CollisionPostSolve(arb)
+	}
+}
+
+// Calls the separate callbacks of the handler and the bodies.
+func (arb *Arbiter) callSeparate(space *Space) {
+	if arb.handler != nil && arb.handler.Separate != nil {
+		arb.handler.Separate(arb, space)
+	}
+	if arb.BodyA.CallbackHandler != nil {
+		arb.BodyA.CallbackHandler.CollisionExit(arb)
+	}
+	if arb.BodyB.CallbackHandler != nil {
+		arb.BodyB.CallbackHandler.CollisionExit(arb)
+	}
+}
+
+// Ignores the collision until the shapes separate.
 func (arb *Arbiter) Ignore() {
 	arb.state = arbiterStateIgnore
 }
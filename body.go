@@ -1,7 +1,9 @@
 package chipmunk
 
 import (
+	"errors"
 	"math"
+	"time"
 )
 
 type ComponentNode struct {
@@ -15,8 +17,9 @@ type UpdatePositionFunction func(body *Body, dt float32)
 type UpdateVelocityFunction func(body *Body, gravity Vect, damping, dt float32)
 
 const (
-	BodyType_Static  = BodyType(0)
-	BodyType_Dynamic = BodyType(1)
+	BodyType_Static    = BodyType(0)
+	BodyType_Dynamic   = BodyType(1)
+	BodyType_Kinematic = BodyType(2)
 )
 
 var Inf = float32(math.Inf(1))
@@ -44,6 +47,8 @@ type Body struct {
 
 	/// Position of the rigid body's center of gravity.
 	p Vect
+	// Center of gravity in body local coordinates, relative to the body's position.
+	cog Vect
 	/// Velocity of the rigid body's center of gravity.
 	v Vect
 	/// Force acting on the rigid body's center of gravity.
@@ -66,6 +71,11 @@ type Body struct {
 	v_bias Vect
 	w_bias float32
 
+	// Position and angle before the last step, and the space stamp of that step.
+	prevP     Vect
+	prevA     float32
+	prevStamp time.Duration
+
 	/// User definable data pointer.
 	/// Generally this points to your the game object class so you can access it
 	/// when given a cpBody reference in a callback.
@@ -85,14 +95,36 @@ type Body struct {
 
 	node ComponentNode
 
+	// Linked lists of the arbiters and constraints this body is part of.
+	arbiterList    *ArbiterEdge
+	constraintList Constraint
+
 	hash HashValue
 
+	// Identifies the body when the space is serialized, constraints reference their bodies by it.
+	// Bodies without an ID are given one in the encoded space.
+	ID uint32
+
 	deleted bool
 	Enabled bool
 
 	idleTime float32
 
 	IgnoreGravity bool
+
+	// Enables continuous collision detection for the body.
+	// Its shapes are swept over each step so they can't tunnel through thin shapes when moving fast.
+	// The shapes are swept as circles and their rotation is ignored. Segments use a circle of their radius
+	// at their middle and polygons the largest circle around their center that fits in them, so the ends of
+	// segments and the corners of polygons can still pass through thin shapes.
+	// Other shapes are only found if they are near the path of the bullet at the start of the step.
+	Bullet bool
+
+	kinematic bool
+	// Set when a shape with a mass is added, removed or changed, the mass is recomputed at the next step.
+	massDirty bool
+	// Mass and moment of the body before it was made static or kinematic, restored by SetType().
+	dynamicM, dynamicI float32
 }
 
 func NewBodyStatic() (body *Body) {
@@ -109,6 +141,23 @@ func NewBodyStatic() (body *Body) {
 	return
 }
 
+// Creates a kinematic body.
+// Kinematic bodies have an infinite mass and are moved by the velocity you set,
+// they push dynamic bodies but gravity, forces and collisions don't affect them.
+func NewBodyKinematic() (body *Body) {
+
+	body = &Body{}
+	body.Shapes = make([]*Shape, 0)
+	body.SetMass(Inf)
+	body.SetMoment(Inf)
+	body.IgnoreGravity = true
+	body.kinematic = true
+	body.SetAngle(0)
+	body.Enabled = true
+
+	return
+}
+
 func NewBody(mass, i float32) (body *Body) {
 
 	body = &Body{}
@@ -124,16 +173,60 @@ func NewBody(mass, i float32) (body *Body) {
 func (body *Body) AddShape(shape *Shape) {
 	body.Shapes = append(body.Shapes, shape)
 	shape.Body = body
+	if shape.Mass() > 0 {
+		body.massDirty = true
+	}
+}
+
+// Removes the shape from the body and from its space, the mass of the body is recomputed from the remaining shapes.
+// A shape removed from a space can't be used again, like after Space.RemoveShape().
+// Returns ErrNilObject, ErrNoBody if the shape isn't attached to the body, or ErrSpaceLocked.
+func (body *Body) RemoveShape(shape *Shape) error {
+	if shape == nil {
+		return ErrNilObject
+	}
+	if shape.Body != body {
+		return ErrNoBody
+	}
+	// Space.RemoveShape() clears the shape class the mass is computed from.
+	massive := shape.Mass() > 0
+	if shape.space != nil {
+		if err := shape.space.TryRemoveShape(shape); err != nil {
+			return err
+		}
+	}
+
+	for i, s := range body.Shapes {
+		if s == shape {
+			copy(body.Shapes[i:], body.Shapes[i+1:])
+			body.Shapes[len(body.Shapes)-1] = nil
+			body.Shapes = body.Shapes[:len(body.Shapes)-1]
+			break
+		}
+	}
+	shape.Body = nil
+	if massive {
+		body.massDirty = true
+	}
+	return nil
 }
 
 func (body *Body) Clone() *Body {
 	clone := *body
 	clone.Shapes = make([]*Shape, 0)
 	for _, shape := range body.Shapes {
-		clone.AddShape(shape.Clone())
+		// Shapes removed from the space stay in body.Shapes.
+		if shape.Body == body {
+			clone.AddShape(shape.Clone())
+		}
 	}
 	clone.space = nil
 	clone.hash = 0
+	clone.arbiterList = nil
+	clone.constraintList = nil
+	if !body.IsStatic() {
+		clone.node = ComponentNode{}
+	}
 	return &clone
 }
 
@@ -149,6 +242,23 @@ func (body *Body) KineticEnergy() float32 {
 	return vsq + wsq
 }
 
+// Returns an error if the body can't be simulated.
+func (body *Body) validate() error {
+	if body.Type() == BodyType_Dynamic {
+		if !isPositive(body.m) {
+			return ErrInvalidMass
+		}
+		if !isPositive(body.i) {
+			return ErrInvalidMoment
+		}
+	}
+	if isNaN(body.p.X) || isNaN(body.p.Y) || isNaN(body.v.X) || isNaN(body.v.Y) ||
+		isNaN(body.a) || isNaN(body.w) {
+		return ErrInvalidPosition
+	}
+	return nil
+}
+
 func (body *Body) SetMass(mass float32) {
 	if mass <= 0 {
 		panic("Mass must be positive and non-zero.")
@@ -169,6 +279,80 @@ func (body *Body) SetMoment(moment float32) {
 	body.i_inv = 1 / moment
 }
 
+// Sets the mass and the moment of the body from the area of its shapes and the density.
+// The center of gravity is kept as an offset from the body's position,
+// so the position, the shapes and the anchors of constraints stay in place.
+// Static and kinematic bodies keep an infinite mass, they get the new mass when they are made dynamic.
+func (body *Body) SetMassPropertiesFromShapes(density float32) error {
+	return body.setMassProperties(func(shape *Shape) float32 {
+		return density * shape.area()
+	})
+}
+
+// Recomputes the mass and the moment of the body from the mass of its shapes
+// if a shape with a mass was added, removed or changed since the last time.
+func (body *Body) updateMass() {
+	if !body.massDirty {
+		return
+	}
+	body.massDirty = false
+	// The masses of the shapes are checked when they are set, so this only fails
+	// when no shape has a mass left, or they are points without a moment.
+	// The body keeps its mass then.
+	body.setMassProperties((*Shape).Mass)
+}
+
+func (body *Body) setMassProperties(massOf func(shape *Shape) float32) error {
+	mass := float32(0)
+	cog := Vector_Zero
+	for _, shape := range body.Shapes {
+		if shape.Body != body {
+			continue
+		}
+		m := massOf(shape)
+		mass += m
+		cog = Add(cog, Mult(shape.ShapeClass.centroid(), m))
+	}
+	if !isPositive(mass) {
+		return ErrInvalidMass
+	}
+	cog = Mult(cog, 1/mass)
+
+	// Moment() is around the body's position, move it to the shape's centroid and then to the center of gravity.
+	moment := float32(0)
+	for _, shape := range body.Shapes {
+		if shape.Body != body {
+			continue
+		}
+		m := massOf(shape)
+		c := shape.ShapeClass.centroid()
+		moment += shape.ShapeClass.Moment(m) - m*LengthSqr(c) + m*DistSqr(c, cog)
+	}
+	if !isPositive(moment) {
+		return ErrInvalidMoment
+	}
+
+	if cog != body.cog {
+		// Keep the body's position where it is and move the center of gravity instead.
+		// The velocity changes with it, as the center of gravity now moves along another point of the body.
+		pos, prevPos := body.Position(), body.prevPosition()
+		p := body.p
+		body.cog = cog
+		body.p = Add(pos, RotateVect(cog, Rotation{body.rot.X, body.rot.Y}))
+		prevRot := FromAngle(body.prevA)
+		body.prevP = Add(prevPos, RotateVect(cog, Rotation{prevRot.X, prevRot.Y}))
+		body.v = Add(body.v, Mult(Perp(Sub(body.p, p)), body.w))
+	}
+
+	if body.Type() == BodyType_Dynamic {
+		body.SetMass(mass)
+		body.SetMoment(moment)
+	} else {
+		body.dynamicM, body.dynamicI = mass, moment
+	}
+	return nil
+}
+
 func (body *Body) Moment() float32 {
 	return body.i
 }
@@ -177,9 +361,12 @@ func (body *Body) MomentIsInf() bool {
 	return math.IsInf(float64(body.i), 0)
 }
 
+// Sets the angle of the body, rotating it around its position.
 func (body *Body) SetAngle(angle float32) {
 	body.BodyActivate()
+	pos := body.Position()
 	body.setAngle(angle)
+	body.p = Add(pos, RotateVect(body.cog, Rotation{body.rot.X, body.rot.Y}))
 }
 
 func (body *Body) AddAngle(angle float32) {
@@ -195,21 +382,32 @@ func (body *Body) setAngle(angle float32) {
 	body.rot = FromAngle(angle)
 }
 
+// Wakes up the body if it's sleeping, or resets the idle timer if it's active.
 func (body *Body) BodyActivate() {
-	//TODO: make it work with sleeping
-	if body.IsStatic() {
+	if body.IsStatic() || body.IsRogue() {
 		return
 	}
 
-	if !body.IsRogue() {
-		body.node.IdleTime = 0
-		root := body.ComponentRoot()
-		if root != nil {
-			root.ComponentActive()
+	body.node.IdleTime = 0
+	body.ComponentRoot().ComponentActive()
+}
+
+// Wakes up any sleeping bodies touching the static body.
+// If filter is not nil, only bodies touching that shape are woken up.
+func (body *Body) ActivateStatic(filter *Shape) {
+	if !body.IsStatic() {
+		return
+	}
+
+	for edge := body.arbiterList; edge != nil; edge = edge.Next {
+		arb := edge.Arbiter
+		if filter == nil || filter == arb.ShapeA || filter == arb.ShapeB {
+			edge.Other.BodyActivate()
 		}
 	}
 }
 
+// Returns the root body of the sleeping component this body belongs to, or nil.
 func (body *Body) ComponentRoot() *Body {
 	if body != nil {
 		return body.node.Root
@@ -217,39 +415,156 @@ func (body *Body) ComponentRoot() *Body {
 	return nil
 }
 
-func (body *Body) ComponentActive() {
-	if body.IsSleeping() || body.IsRogue() {
+// Wakes up every body of the sleeping component rooted at this body.
+func (root *Body) ComponentActive() {
+	if root == nil || !root.IsSleeping() {
 		return
 	}
-	return
 
-	space := body.space
-	b := body
-	for b != nil {
-		next := b.node.Next
+	space := root.space
+	body := root
+	for body != nil {
+		next := body.node.Next
 
-		b.node.IdleTime = 0
-		b.node.Root = nil
-		b.node.Next = nil
+		body.node.IdleTime = 0
+		body.node.Root = nil
+		body.node.Next = nil
 		space.ActiveBody(body)
 
-		b = next
+		body = next
 	}
 
-	//for i,sleeping
-	//cpArrayDeleteObj(space->sleepingComponents, root);
-	foundIndex := -1
-	for i, sleepBody := range space.sleepingComponents {
-		if sleepBody == b {
-			foundIndex = i
-			break
+	space.sleepingComponents = deleteBody(space.sleepingComponents, root)
+}
+
+func (root *Body) componentAdd(body *Body) {
+	body.node.Root = root
+	if body != root {
+		body.node.Next = root.node.Next
+		root.node.Next = body
+	}
+}
+
+// Returns true if any body of the component has been idle for less than threshold.
+func (root *Body) componentIsActive(threshold float32) bool {
+	for body := root; body != nil; body = body.node.Next {
+		if body.node.IdleTime < threshold {
+			return true
 		}
 	}
-	if foundIndex != -1 {
-		space.sleepingComponents = append(space.sleepingComponents[:foundIndex],
-			space.sleepingComponents[foundIndex+1:]...)
+	return false
+}
+
+// Forces the body to fall asleep immediately, even if it's in midair.
+func (body *Body) Sleep() error {
+	return body.SleepWithGroup(nil)
+}
+
+// Forces the body to fall asleep immediately together with group.
+// The bodies will wake up together. group must already be sleeping, or nil to start a new group.
+func (body *Body) SleepWithGroup(group *Body) error {
+	if body.IsStatic() || body.IsRogue() || body.IsKinematic() {
+		return errors.New("Rogue, static and kinematic bodies cannot be put to sleep.")
+	}
+
+	space := body.space
+	if space.locked > 0 {
+		return ErrSpaceLocked
 	}
 
+	if group != nil && !group.IsSleeping() {
+		return errors.New("Cannot use a non-sleeping body as a group identifier.")
+	}
+
+	if body.IsSleeping() {
+		if body.ComponentRoot() != group.ComponentRoot() {
+			return errors.New("The body is already sleeping and it's group cannot be reassigned.")
+		}
+		return nil
+	}
+
+	body.UpdateShapes()
+	space.deactivateBody(body)
+
+	if group != nil {
+		root := group.ComponentRoot()
+		body.node = ComponentNode{Root: root, Next: root.node.Next}
+		root.node.Next = body
+	} else {
+		body.node = ComponentNode{Root: body}
+		space.sleepingComponents = append(space.sleepingComponents, body)
+	}
+
+	return nil
+}
+
+// Calls fnc for every arbiter the body is currently touching.
+// The contact graph has to be enabled with Space.SetEnableContactGraph() unless sleeping is enabled.
+// The edge gives access to the other body, the normal and the impulse from this body's perspective.
+func (body *Body) EachArbiter(fnc func(edge *ArbiterEdge)) {
+	edge := body.arbiterList
+	for edge != nil {
+		next := edge.Next
+		fnc(edge)
+		edge = next
+	}
+}
+
+// Calls fnc for every constraint attached to the body.
+func (body *Body) EachConstraint(fnc func(constraint Constraint)) {
+	constraint := body.constraintList
+	for constraint != nil {
+		next := constraint.Constraint().next(body)
+		fnc(constraint)
+		constraint = next
+	}
+}
+
+func (body *Body) pushArbiter(arb *Arbiter) {
+	edge := arb.edgeFor(body)
+	if body == arb.BodyA {
+		edge.Other = arb.BodyB
+	} else {
+		edge.Other = arb.BodyA
+	}
+
+	next := body.arbiterList
+	edge.Next = next
+	edge.Prev = nil
+	if next != nil {
+		next.Prev = edge
+	}
+	body.arbiterList = edge
+}
+
+func (body *Body) pushConstraint(constraint Constraint) {
+	con := constraint.Constraint()
+	if con.BodyA == body {
+		con.nextA = body.constraintList
+	} else {
+		con.nextB = body.constraintList
+	}
+	body.constraintList = constraint
+}
+
+func (body *Body) removeConstraint(constraint Constraint) {
+	body.constraintList = filterConstraints(body.constraintList, body, constraint)
+}
+
+func filterConstraints(node Constraint, body *Body, filter Constraint) Constraint {
+	if node == nil {
+		return nil
+	}
+
+	con := node.Constraint()
+	if node == filter {
+		return con.next(body)
+	} else if con.BodyA == body {
+		con.nextA = filterConstraints(con.nextA, body, filter)
+	} else {
+		con.nextB = filterConstraints(con.nextB, body, filter)
+	}
+	return node
 }
 
 func (body *Body) IsRogue() bool {
@@ -264,14 +579,110 @@ func (body *Body) IsStatic() bool {
 	return math.IsInf(float64(body.node.IdleTime), 0)
 }
 
+func (body *Body) IsKinematic() bool {
+	return body.kinematic
+}
+
+func (body *Body) Type() BodyType {
+	if body.IsStatic() {
+		return BodyType_Static
+	}
+	if body.kinematic {
+		return BodyType_Kinematic
+	}
+	return BodyType_Dynamic
+}
+
+// Changes the type of the body and moves its shapes to the index of the new type.
+// Static and kinematic bodies get an infinite mass and moment and stop moving.
+// Dynamic bodies get back the mass and moment they had before they were made static or kinematic,
+// a body that was never dynamic needs a mass set with SetMass() first or ErrInvalidMass is returned.
+func (body *Body) SetType(bodyType BodyType) error {
+	oldType := body.Type()
+	if bodyType == oldType {
+		return nil
+	}
+	if bodyType > BodyType_Kinematic {
+		return ErrInvalidBodyType
+	}
+
+	space := body.space
+	if space != nil && space.locked != 0 {
+		return ErrSpaceLocked
+	}
+
+	m, i := Inf, Inf
+	if bodyType == BodyType_Dynamic {
+		body.updateMass()
+		m, i = body.m, body.i
+		if math.IsInf(float64(m), 0) {
+			m, i = body.dynamicM, body.dynamicI
+		}
+		if !(m > 0) {
+			return ErrInvalidMass
+		}
+	}
+
+	// Wake up the bodies touching it, a sleeping body also has its shapes moved back to the active index.
+	if oldType == BodyType_Static {
+		body.ActivateStatic(nil)
+	} else {
+		body.BodyActivate()
+	}
+
+	if oldType == BodyType_Dynamic {
+		body.dynamicM, body.dynamicI = body.m, body.i
+	}
+	body.m, body.m_inv = m, 1/m
+	body.i, body.i_inv = i, 1/i
+	if bodyType != BodyType_Dynamic {
+		body.v, body.w = Vector_Zero, 0
+	}
+
+	body.kinematic = bodyType == BodyType_Kinematic
+	if bodyType == BodyType_Static {
+		body.node = ComponentNode{IdleTime: Inf}
+	} else {
+		body.node = ComponentNode{}
+	}
+
+	if space == nil || (oldType != BodyType_Static && bodyType != BodyType_Static) {
+		return nil
+	}
+
+	from, to := space.activeShapes, space.staticShapes
+	if oldType == BodyType_Static {
+		from, to = to, from
+		if !body.deleted {
+			space.Bodies = append(space.Bodies, body)
+		}
+	} else {
+		space.Bodies = deleteBody(space.Bodies, body)
+	}
+	for _, shape := range body.Shapes {
+		if shape.space == space {
+			from.Remove(shape)
+			shape.Update()
+			to.Insert(shape)
+		}
+	}
+
+	return nil
+}
+
 func (body *Body) UpdateShapes() {
 	for _, shape := range body.Shapes {
-		shape.Update()
+		// Shapes removed from the space have no body left to follow.
+		if shape.Body == body {
+			shape.Update()
+		}
 	}
 }
 
+// Sets the position of the body, the point its shapes and the anchors of constraints are relative to.
 func (body *Body) SetPosition(pos Vect) {
-	body.p = pos
+	body.BodyActivate()
+	body.p = Add(pos, RotateVect(body.cog, Rotation{body.rot.X, body.rot.Y}))
 }
 
 func (body *Body) AddForce(x, y float32) {
@@ -287,16 +698,19 @@ func (body *Body) SetForce(x, y float32) {
 }
 
 func (body *Body) AddVelocity(x, y float32) {
+	body.BodyActivate()
 	body.v.X += x
 	body.v.Y += y
 }
 
 func (body *Body) SetVelocity(x, y float32) {
+	body.BodyActivate()
 	body.v.X = x
 	body.v.Y = y
 }
 
 func (body *Body) AddTorque(t float32) {
+	body.BodyActivate()
 	body.t += t
 }
 
@@ -325,14 +739,17 @@ func (body *Body) AngularVelocity() float32 {
 }
 
 func (body *Body) SetTorque(t float32) {
+	body.BodyActivate()
 	body.t = t
 }
 
 func (body *Body) AddAngularVelocity(w float32) {
+	body.BodyActivate()
 	body.w += w
 }
 
 func (body *Body) SetAngularVelocity(w float32) {
+	body.BodyActivate()
 	body.w = w
 }
 
@@ -340,18 +757,56 @@ func (body *Body) Velocity() Vect {
 	return body.v
 }
 
+// Returns the position of the body, which differs from the center of gravity when it isn't at the origin of the shapes.
 func (body *Body) Position() Vect {
-	return body.p
+	return Sub(body.p, RotateVect(body.cog, Rotation{body.rot.X, body.rot.Y}))
+}
+
+// Returns the position of the body before the last step.
+func (body *Body) prevPosition() Vect {
+	rot := FromAngle(body.prevA)
+	return Sub(body.prevP, RotateVect(body.cog, Rotation{rot.X, rot.Y}))
 }
 
 func (body *Body) Angle() float32 {
 	return body.a
 }
 
+// Returns the position between the one before the last step (alpha 0) and the current one (alpha 1).
+// Bodies that didn't move in the last step return their current position.
+func (body *Body) InterpolatedPosition(alpha float32) Vect {
+	if !body.movedLastStep() {
+		return body.Position()
+	}
+	return Lerp(body.prevPosition(), body.Position(), alpha)
+}
+
+// Returns the angle between the one before the last step (alpha 0) and the current one (alpha 1).
+func (body *Body) InterpolatedAngle(alpha float32) float32 {
+	if !body.movedLastStep() {
+		return body.a
+	}
+	return body.prevA + (body.a-body.prevA)*alpha
+}
+
+func (body *Body) movedLastStep() bool {
+	return body.space != nil && body.prevStamp == body.space.stamp
+}
+
 func (body *Body) Rot() (rx, ry float32) {
 	return body.rot.X, body.rot.Y
 }
 
+// Converts a point from body local coordinates to world coordinates.
+func (body *Body) LocalToWorld(v Vect) Vect {
+	return Add(body.p, RotateVect(Sub(v, body.cog), Rotation{body.rot.X, body.rot.Y}))
+}
+
+// Converts a point from world coordinates to body local coordinates.
+func (body *Body) WorldToLocal(v Vect) Vect {
+	return Add(RotateVectInv(Sub(v, body.p), Rotation{body.rot.X, body.rot.Y}), body.cog)
+}
+
 func (body *Body) UpdatePosition(dt float32) {
 	if body.UpdatePositionFunc != nil {
 		body.UpdatePositionFunc(body, dt)
@@ -369,6 +824,10 @@ func (body *Body) UpdateVelocity(gravity Vect, ldamping, adamping, dt float32) {
 		body.UpdateVelocityFunc(body, gravity, ldamping, dt)
 		return
 	}
+	// Kinematic bodies keep the velocity they were given.
+	if body.kinematic {
+		return
+	}
 	body.v = Add(Mult(body.v, ldamping), Mult(Add(gravity, Mult(body.f, body.m_inv)), dt))
 
 	body.w = (body.w * adamping) + (body.t * body.i_inv * dt)
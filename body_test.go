@@ -0,0 +1,363 @@
+package chipmunk
+
+import (
+	"math"
+	"testing"
+)
+
+// Returns a space from newGroundSpace() with a ball resting on the platform.
+func newRestingBallSpace() (space *Space, ground, ball *Body) {
+	space, ground = newGroundSpace()
+	ball = NewBody(1, 10)
+	ball.AddShape(NewCircle(Vector_Zero, 5))
+	ball.SetPosition(Vect{0, 20})
+	space.AddBody(ball)
+	return
+}
+
+func TestBodyEachArbiter(t *testing.T) {
+	space, platform, ball := newRestingBallSpace()
+	space.SetEnableContactGraph(true)
+	stepSpace(space, 60)
+
+	// The resting ball gets the impulse that stops it from falling each step, 600 * 1/60 upwards.
+	near := func(v, want Vect, tolerance float32) bool {
+		return Dist(v, want) <= tolerance
+	}
+	tests := []struct {
+		body, other     *Body
+		normal, impulse Vect
+	}{
+		{ball, platform, Vect{0, -1}, Vect{0, 10}},
+		{platform, ball, Vect{0, 1}, Vect{0, -10}},
+	}
+	for _, test := range tests {
+		edges := 0
+		test.body.EachArbiter(func(edge *ArbiterEdge) {
+			edges++
+			if edge.Other != test.other {
+				t.Errorf("edge of the body at %v has the wrong other body", test.body.Position())
+			}
+			if n := edge.Normal(); !near(n, test.normal, 1e-3) {
+				t.Errorf("edge normal is %v, want %v", n, test.normal)
+			}
+			if j := edge.Impulse(); !near(j, test.impulse, 0.5) {
+				t.Errorf("edge impulse is %v, want %v", j, test.impulse)
+			}
+
+			// TotalImpulse() is the impulse on BodyA of the arbiter.
+			arb, want := edge.Arbiter, test.impulse
+			if arb.BodyA != test.body {
+				want = Mult(want, -1)
+			}
+			if j := arb.TotalImpulse(); !near(j, want, 0.5) {
+				t.Errorf("TotalImpulse() is %v, want %v", j, want)
+			}
+		})
+		if edges != 1 {
+			t.Errorf("EachArbiter() found %d arbiters, want 1", edges)
+		}
+	}
+
+	// The ball leaves the contact graph once it stops touching the platform.
+	ball.SetPosition(Vect{0, 100})
+	space.Step(1.0 / 60.0)
+	ball.EachArbiter(func(edge *ArbiterEdge) {
+		t.Error("ball in the air has an arbiter")
+	})
+}
+
+func TestBodyEachConstraint(t *testing.T) {
+	space, platform, ball := newRestingBallSpace()
+	other := NewBody(1, 1)
+	space.AddBody(other)
+	pivot := NewPivotJoint(ball, platform)
+	spring := NewDampedSpring(other, ball, Vector_Zero, Vector_Zero, 10, 1, 0)
+	fixed := NewPivotJoint(other, platform)
+	space.AddConstraint(pivot)
+	space.AddConstraint(spring)
+	space.AddConstraint(fixed)
+
+	found := map[Constraint]bool{}
+	ball.EachConstraint(func(constraint Constraint) {
+		found[constraint] = true
+	})
+	if len(found) != 2 || !found[pivot] || !found[spring] {
+		t.Fatalf("EachConstraint() found %d constraints, want the pivot and the spring", len(found))
+	}
+
+	// Constraints can be removed while iterating.
+	ball.EachConstraint(func(constraint Constraint) {
+		space.RemoveConstraint(constraint)
+	})
+	ball.EachConstraint(func(constraint Constraint) {
+		t.Error("removed constraint is still attached to the ball")
+	})
+	var left []Constraint
+	other.EachConstraint(func(constraint Constraint) {
+		left = append(left, constraint)
+	})
+	if len(left) != 1 || left[0] != fixed {
+		t.Fatalf("other body has %d constraints left, want the pivot to the platform", len(left))
+	}
+}
+
+func closeTo(a, b float32) bool {
+	return FAbs(a-b) <= 1e-4*FMax(1, FAbs(b))
+}
+
+func TestMassHelpers(t *testing.T) {
+	square := Vertices{{-1, -2}, {-1, 2}, {1, 2}, {1, -2}}
+	if area := AreaForPoly(square); !closeTo(area, AreaForBox(2, 4)) {
+		t.Fatalf("AreaForPoly() = %v", area)
+	}
+	if moment := MomentForPoly(3, square, Vector_Zero); !closeTo(moment, MomentForBox(3, 2, 4)) {
+		t.Fatalf("MomentForPoly() = %v, MomentForBox() = %v", moment, MomentForBox(3, 2, 4))
+	}
+
+	offset := Vect{5, -1}
+	if c := CentroidForPoly(NewPolygon(square, offset).GetAsPolygon().Verts); !closeTo(c.X, 5) || !closeTo(c.Y, -1) {
+		t.Fatalf("CentroidForPoly() = %v", c)
+	}
+	// Parallel axis theorem.
+	if moment := MomentForPoly(3, square, offset); !closeTo(moment, MomentForBox(3, 2, 4)+3*LengthSqr(offset)) {
+		t.Fatalf("MomentForPoly() with an offset = %v", moment)
+	}
+	if moment := MomentForCircle(2, 0, 1, offset); !closeTo(moment, 1+2*LengthSqr(offset)) {
+		t.Fatalf("MomentForCircle() = %v", moment)
+	}
+	if area := AreaForSegment(Vect{0, 0}, Vect{2, 0}, 1); !closeTo(area, math.Pi+4) {
+		t.Fatalf("AreaForSegment() = %v", area)
+	}
+}
+
+func TestBodySetMassPropertiesFromShapes(t *testing.T) {
+	body := NewBody(1, 1)
+	body.AddShape(NewBox(Vect{0, 0}, 2, 2))
+	body.AddShape(NewPolygon(Vertices{{3, -1}, {3, 1}, {5, 1}, {5, -1}}, Vector_Zero))
+	body.SetPosition(Vect{10, 10})
+	body.SetAngle(math.Pi / 2)
+	body.UpdateShapes()
+
+	var bbs []AABB
+	for _, shape := range body.Shapes {
+		bbs = append(bbs, shape.BB)
+	}
+
+	if err := body.SetMassPropertiesFromShapes(2); err != nil {
+		t.Fatal(err)
+	}
+
+	// Two 2x2 squares with a mass of 8 each, 4 away from each other.
+	if !closeTo(body.Mass(), 16) {
+		t.Fatalf("mass is %v", body.Mass())
+	}
+	if want := 2 * (MomentForBox(8, 2, 2) + 8*4); !closeTo(body.Moment(), want) {
+		t.Fatalf("moment is %v, want %v", body.Moment(), want)
+	}
+
+	// The center of gravity moved between the squares, rotated by the body's angle, and the body stayed.
+	if p := body.Position(); p != (Vect{10, 10}) {
+		t.Fatalf("body moved to %v", p)
+	}
+	if p := body.p; !closeTo(p.X, 10) || !closeTo(p.Y, 12) {
+		t.Fatalf("center of gravity is at %v", p)
+	}
+	for i, shape := range body.Shapes {
+		a, b := shape.BB, bbs[i]
+		if !closeTo(a.Lower.X, b.Lower.X) || !closeTo(a.Lower.Y, b.Lower.Y) ||
+			!closeTo(a.Upper.X, b.Upper.X) || !closeTo(a.Upper.Y, b.Upper.Y) {
+			t.Fatalf("shape %d moved from %v to %v", i, b, a)
+		}
+	}
+
+	if err := NewBody(1, 1).SetMassPropertiesFromShapes(1); err != ErrInvalidMass {
+		t.Fatalf("body without shapes returned %v", err)
+	}
+
+	// Static bodies keep an infinite mass until they are made dynamic.
+	static := NewBodyStatic()
+	static.AddShape(NewCircle(Vector_Zero, 1))
+	if err := static.SetMassPropertiesFromShapes(1); err != nil {
+		t.Fatal(err)
+	}
+	if !static.IsStatic() || !math.IsInf(float64(static.Mass()), 1) {
+		t.Fatalf("static body has a mass of %v", static.Mass())
+	}
+	if err := static.SetType(BodyType_Dynamic); err != nil {
+		t.Fatal(err)
+	}
+	if !closeTo(static.Mass(), math.Pi) {
+		t.Fatalf("dynamic body has a mass of %v", static.Mass())
+	}
+}
+
+func TestSetMassPropertiesKeepsJoints(t *testing.T) {
+	space := NewSpace()
+	static := NewBodyStatic()
+	space.AddBody(static)
+
+	body := NewBody(1, 1)
+	body.AddShape(NewBox(Vect{0, 0}, 2, 2))
+	body.AddShape(NewBox(Vect{4, 0}, 2, 2))
+	body.SetPosition(Vect{10, 10})
+	space.AddBody(body)
+	space.AddConstraint(NewPivotJointAnchor(body, static, Vector_Zero, Vect{10, 10}))
+
+	body.SetAngularVelocity(1)
+	if err := body.SetMassPropertiesFromShapes(1); err != nil {
+		t.Fatal(err)
+	}
+	if p := body.Position(); p != (Vect{10, 10}) {
+		t.Fatalf("body moved to %v", p)
+	}
+	// The body keeps spinning around the pivot, only the center of gravity moves.
+	v := Add(body.Velocity(), Mult(Perp(Sub(body.Position(), body.p)), body.AngularVelocity()))
+	if !closeTo(v.X, 0) || !closeTo(v.Y, 0) {
+		t.Fatalf("pivot moves at %v", v)
+	}
+
+	stepSpace(space, 60)
+	if d := Dist(body.Position(), Vect{10, 10}); d > 0.01 {
+		t.Fatalf("body is %v away from the pivot", d)
+	}
+	if a := body.Angle(); a < 0.9 || a > 1.1 {
+		t.Fatalf("body turned by %v", a)
+	}
+}
+
+func TestShapeMassUpdatesBody(t *testing.T) {
+	space := NewSpace()
+
+	body := NewBody(1, 1)
+	left := NewBox(Vect{0, 0}, 2, 2)
+	right := NewBox(Vect{4, 0}, 2, 2)
+	left.SetDensity(2)
+	right.SetDensity(2)
+	body.AddShape(left)
+	body.AddShape(right)
+	space.AddBody(body)
+
+	plain := NewBody(5, 5)
+	plain.AddShape(NewCircle(Vector_Zero, 1))
+	plain.SetPosition(Vect{50, 50})
+	space.AddBody(plain)
+
+	// The mass is only recomputed when the space steps.
+	if body.Mass() != 1 {
+		t.Fatalf("mass changed to %v before the step", body.Mass())
+	}
+	space.Step(1.0 / 60.0)
+	if !closeTo(body.Mass(), 16) || !closeTo(body.p.X, 2) {
+		t.Fatalf("mass is %v with the center of gravity at %v", body.Mass(), body.p)
+	}
+	if plain.Mass() != 5 || plain.Moment() != 5 {
+		t.Fatal("body without shape masses changed its mass")
+	}
+
+	// Breaking off a shape moves the center of gravity to the remaining one.
+	if err := space.TryRemoveShape(left); err != nil {
+		t.Fatal(err)
+	}
+	space.Step(1.0 / 60.0)
+	if !closeTo(body.Mass(), 8) || !closeTo(body.Moment(), MomentForBox(8, 2, 2)) || !closeTo(body.p.X, 4) {
+		t.Fatalf("mass is %v and moment %v with the center of gravity at %v", body.Mass(), body.Moment(), body.p)
+	}
+
+	if err := right.SetMass(3); err != nil {
+		t.Fatal(err)
+	}
+	// Invalid masses are rejected when they are set and don't change the shape.
+	nan, inf := float32(math.NaN()), float32(math.Inf(1))
+	for _, m := range []float32{-1, nan, inf} {
+		if err := right.SetMass(m); err != ErrInvalidMass {
+			t.Errorf("SetMass(%v) = %v, want %v", m, err, ErrInvalidMass)
+		}
+		if err := right.SetDensity(m); err != ErrInvalidMass {
+			t.Errorf("SetDensity(%v) = %v, want %v", m, err, ErrInvalidMass)
+		}
+		def := ShapeDef{Type: ShapeDefCircle, Radius: 1, Density: m}
+		if _, err := def.NewShape(); err != ErrInvalidMass {
+			t.Errorf("NewShape() with a density of %v = %v, want %v", m, err, ErrInvalidMass)
+		}
+	}
+	space.Step(1.0 / 60.0)
+	if !closeTo(body.Mass(), 3) || !closeTo(right.Density(), 0.75) {
+		t.Fatalf("mass is %v and density %v", body.Mass(), right.Density())
+	}
+
+	data, err := space.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, _, err := UnmarshalSpaceBinary(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, body := range loaded.Bodies {
+		for _, shape := range body.Shapes {
+			if shape.GetAsBox() != nil && shape.Mass() != 3 {
+				t.Fatalf("loaded shape has a mass of %v", shape.Mass())
+			}
+		}
+	}
+}
+
+func TestBodyRemoveShape(t *testing.T) {
+	space := NewSpace()
+	body := NewBody(1, 1)
+	left := NewBox(Vect{0, 0}, 2, 2)
+	right := NewBox(Vect{4, 0}, 2, 2)
+	left.SetDensity(2)
+	right.SetDensity(2)
+	body.AddShape(left)
+	body.AddShape(right)
+	body.SetPosition(Vect{10, 10})
+	space.AddBody(body)
+	space.Step(1.0 / 60.0)
+	if !closeTo(body.Mass(), 16) || !closeTo(body.cog.X, 2) {
+		t.Fatalf("mass is %v with the center of gravity at %v", body.Mass(), body.cog)
+	}
+
+	if err := body.RemoveShape(left); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Shapes) != 1 || body.Shapes[0] != right || left.Body != nil || left.space != nil {
+		t.Fatal("shape wasn't removed from the body")
+	}
+	if n := space.activeShapes.Count(); n != 1 {
+		t.Fatalf("space has %d shapes, want 1", n)
+	}
+	space.Step(1.0 / 60.0)
+	if !closeTo(body.Mass(), 8) || !closeTo(body.Moment(), MomentForBox(8, 2, 2)) || Dist(body.cog, Vect{4, 0}) > 1e-4 {
+		t.Fatalf("mass is %v and moment %v with the center of gravity at %v", body.Mass(), body.Moment(), body.cog)
+	}
+	if p := body.Position(); Dist(p, Vect{10, 10}) > 1e-3 {
+		t.Fatalf("body moved to %v", p)
+	}
+
+	if err := body.RemoveShape(left); err != ErrNoBody {
+		t.Errorf("RemoveShape() of a removed shape = %v, want %v", err, ErrNoBody)
+	}
+	if err := body.RemoveShape(nil); err != ErrNilObject {
+		t.Errorf("RemoveShape(nil) = %v, want %v", err, ErrNilObject)
+	}
+	space.lock()
+	if err := body.RemoveShape(right); err != ErrSpaceLocked || len(body.Shapes) != 1 {
+		t.Errorf("RemoveShape() while locked = %v, want %v", err, ErrSpaceLocked)
+	}
+	space.unlock(false)
+
+	// A shape of a body outside of a space can be moved to another body.
+	other := NewBody(1, 1)
+	circle := NewCircle(Vector_Zero, 1)
+	other.AddShape(circle)
+	if err := other.RemoveShape(circle); err != nil || len(other.Shapes) != 0 {
+		t.Fatalf("RemoveShape() = %v with %d shapes left", err, len(other.Shapes))
+	}
+	body.AddShape(circle)
+	space.AddShape(circle)
+	if circle.space != space || circle.Body != body {
+		t.Fatal("removed shape can't be added to another body")
+	}
+}
@@ -51,7 +51,15 @@ func NewBox(pos Vect, w, h float32) *Shape {
 }
 
 func (box *BoxShape) Moment(mass float32) float32 {
-	return (float32(mass) * (box.Width*box.Width + box.Height*box.Height) / 12.0)
+	return MomentForBox(mass, box.Width, box.Height) + mass*LengthSqr(box.Position)
+}
+
+func (box *BoxShape) area() float32 {
+	return AreaForBox(box.Width, box.Height)
+}
+
+func (box *BoxShape) centroid() Vect {
+	return box.Position
 }
 
 // Recalculates the internal Polygon with the Width, Height and Position.
@@ -85,9 +93,8 @@ func (box *BoxShape) ShapeType() ShapeType {
 // Returns ShapeType_Box. Needed to implemet the ShapeClass interface.
 func (box *BoxShape) Clone(s *Shape) ShapeClass {
 	clone := *box
-	clone.Polygon = &PolygonShape{Shape: s}
+	clone.Polygon = box.Polygon.Clone2(s)
 	clone.Shape = s
-	clone.UpdatePoly()
 	return &clone
 }
 
@@ -96,6 +103,14 @@ func (box *BoxShape) update(xf Transform) AABB {
 	return box.Polygon.update(xf)
 }
 
+func (box *BoxShape) segmentQuery(a, b Vect, r float32, info *SegmentQueryInfo) bool {
+	if !box.Polygon.segmentQuery(a, b, r, info) {
+		return false
+	}
+	info.Shape = box.Shape
+	return true
+}
+
 // Returns true if the given point is located inside the box.
 func (box *BoxShape) TestPoint(point Vect) bool {
 	return box.Polygon.TestPoint(point)
@@ -1,2 +1,84 @@
 // chipmunk project chipmunk.go
 package chipmunk
+
+import (
+	"math"
+)
+
+// Returns the moment of inertia of a hollow circle with inner radius r1 and outer radius r2,
+// with its center offset from the center of gravity. r1 is 0 for a solid circle.
+func MomentForCircle(mass, r1, r2 float32, offset Vect) float32 {
+	return mass * (0.5*(r1*r1+r2*r2) + LengthSqr(offset))
+}
+
+// Returns the area of a hollow circle with inner radius r1 and outer radius r2.
+func AreaForCircle(r1, r2 float32) float32 {
+	return math.Pi * FAbs(r1*r1-r2*r2)
+}
+
+// Returns the moment of inertia of a segment from a to b with radius r.
+// Rounded segments are approximated as boxes, which is quite close.
+func MomentForSegment(mass float32, a, b Vect, r float32) float32 {
+	offset := Lerp(a, b, 0.5)
+	length := Dist(b, a) + 2*r
+	return mass * ((length*length+4*r*r)/12 + LengthSqr(offset))
+}
+
+// Returns the area of a segment from a to b with radius r.
+func AreaForSegment(a, b Vect, r float32) float32 {
+	return r * (math.Pi*r + 2*Dist(a, b))
+}
+
+// Returns the moment of inertia of a solid polygon with its vertices offset from the center of gravity.
+// The vertices must be wound clockwise.
+func MomentForPoly(mass float32, verts Vertices, offset Vect) float32 {
+	if len(verts) == 2 {
+		return MomentForSegment(mass, Add(verts[0], offset), Add(verts[1], offset), 0)
+	}
+
+	sum1 := float32(0)
+	sum2 := float32(0)
+	for i := range verts {
+		v1 := Add(verts[i], offset)
+		v2 := Add(verts[(i+1)%len(verts)], offset)
+
+		a := Cross(v2, v1)
+		b := Dot(v1, v1) + Dot(v1, v2) + Dot(v2, v2)
+
+		sum1 += a * b
+		sum2 += a
+	}
+
+	return (mass * sum1) / (6 * sum2)
+}
+
+// Returns the area of a polygon wound clockwise.
+func AreaForPoly(verts Vertices) float32 {
+	return -verts.signedArea()
+}
+
+// Returns the centroid of a polygon.
+func CentroidForPoly(verts Vertices) Vect {
+	sum := float32(0)
+	vsum := Vector_Zero
+	for i := range verts {
+		v1 := verts[i]
+		v2 := verts[(i+1)%len(verts)]
+		cross := Cross(v1, v2)
+
+		sum += cross
+		vsum = Add(vsum, Mult(Add(v1, v2), cross))
+	}
+
+	return Mult(vsum, 1/(3*sum))
+}
+
+// Returns the moment of inertia of a solid box of the given size centered on the center of gravity.
+func MomentForBox(mass, width, height float32) float32 {
+	return mass * (width*width + height*height) / 12
+}
+
+// Returns the area of a box of the given size.
+func AreaForBox(width, height float32) float32 {
+	return FAbs(width * height)
+}
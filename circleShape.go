@@ -1,5 +1,9 @@
 package chipmunk
 
+import (
+	"math"
+)
+
 type CircleShape struct {
 	Shape *Shape
 	// Center of the circle. Call Update() on the parent shape if changed.
@@ -28,7 +32,15 @@ func (circle *CircleShape) ShapeType() ShapeType {
 }
 
 func (circle *CircleShape) Moment(mass float32) float32 {
-	return (float32(mass) * (0.5 * (circle.Radius * circle.Radius))) + LengthSqr(circle.Position)
+	return MomentForCircle(mass, 0, circle.Radius, circle.Position)
+}
+
+func (circle *CircleShape) area() float32 {
+	return AreaForCircle(0, circle.Radius)
+}
+
+func (circle *CircleShape) centroid() Vect {
+	return circle.Position
 }
 
 // Recalculates the global center of the circle and the the bounding box.
@@ -51,6 +63,36 @@ func (circle *CircleShape) Clone(s *Shape) ShapeClass {
 	return &clone
 }
 
+func (circle *CircleShape) segmentQuery(a, b Vect, r float32, info *SegmentQueryInfo) bool {
+	return circleSegmentQuery(circle.Shape, circle.Tc, circle.Radius, a, b, r, info)
+}
+
+// Sweeps a circle of radius r2 from a to b against a circle of radius r1.
+func circleSegmentQuery(shape *Shape, center Vect, r1 float32, a, b Vect, r2 float32, info *SegmentQueryInfo) bool {
+	// offset the line to be relative to the circle
+	da := Sub(a, center)
+	db := Sub(b, center)
+	r := r1 + r2
+
+	qa := Dot(da, da) - 2*Dot(da, db) + Dot(db, db)
+	qb := -2*Dot(da, da) + 2*Dot(da, db)
+	qc := Dot(da, da) - r*r
+
+	det := qb*qb - 4*qa*qc
+
+	if det >= 0 && qa != 0 {
+		t := (-qb - float32(math.Sqrt(float64(det)))) / (2 * qa)
+		if 0 <= t && t <= 1 {
+			n := Normalize(Lerp(da, db, t))
+			info.set(shape, a, b, n, t)
+			info.Point = Sub(info.Point, Mult(n, r2))
+			return true
+		}
+	}
+
+	return false
+}
+
 // Returns true if the given point is located inside the circle.
 func (circle *CircleShape) TestPoint(point Vect) bool {
 	d := Sub(point, circle.Tc)
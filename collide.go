@@ -17,7 +17,7 @@ var collisionHandlers = [numShapes][numShapes]collisionHandler{
 	},
 	ShapeType_Segment: [numShapes]collisionHandler{
 		ShapeType_Circle:  nil,
-		ShapeType_Segment: nil,
+		ShapeType_Segment: segment2segment,
 		ShapeType_Polygon: segment2polygon,
 		ShapeType_Box:     segment2box,
 	},
@@ -99,6 +99,21 @@ func circle2polygon(contacts []*Contact, sA, sB *Shape) int {
 	return circle2polyFunc(contacts, circle, poly)
 }
 
+func segment2segment(contacts []*Contact, sA, sB *Shape) int {
+	seg1, ok := sA.ShapeClass.(*SegmentShape)
+	if !ok {
+		log.Printf("Error: ShapeA not a SegmentShape!")
+		return 0
+	}
+	seg2, ok := sB.ShapeClass.(*SegmentShape)
+	if !ok {
+		log.Printf("Error: ShapeB not a SegmentShape!")
+		return 0
+	}
+
+	return seg2segFunc(contacts, seg1, seg2)
+}
+
 func segment2polygon(contacts []*Contact, sA, sB *Shape) int {
 	segment, ok := sA.ShapeClass.(*SegmentShape)
 	if !ok {
@@ -272,6 +287,123 @@ func circle2segmentFunc(contacts []*Contact, circle *CircleShape, segment *Segme
 	panic("Never reached")
 }
 
+// Returns the parameters of the closest points between the segments p1-q1 and p2-q2.
+func closestPointsSegments(p1, q1, p2, q2 Vect) (s, t float32) {
+	const epsilon = 1e-9
+
+	d1 := Sub(q1, p1)
+	d2 := Sub(q2, p2)
+	r := Sub(p1, p2)
+	a := Dot(d1, d1)
+	e := Dot(d2, d2)
+	f := Dot(d2, r)
+
+	if a <= epsilon && e <= epsilon {
+		return 0, 0
+	}
+
+	if a <= epsilon {
+		return 0, FClamp(f/e, 0, 1)
+	}
+
+	c := Dot(d1, r)
+	if e <= epsilon {
+		return FClamp(-c/a, 0, 1), 0
+	}
+
+	b := Dot(d1, d2)
+	denom := a*e - b*b
+	if denom != 0 {
+		s = FClamp((b*f-c*e)/denom, 0, 1)
+	}
+
+	t = (b*s + f) / e
+	if t < 0 {
+		t = 0
+		s = FClamp(-c/a, 0, 1)
+	} else if t > 1 {
+		t = 1
+		s = FClamp((b-c)/a, 0, 1)
+	}
+
+	return s, t
+}
+
+// Returns the point on the segment a-b that is closest to p.
+func closestPointOnSegment(a, b, p Vect) Vect {
+	delta := Sub(b, a)
+	lenSqr := Dot(delta, delta)
+	if lenSqr == 0 {
+		return a
+	}
+	return Add(a, Mult(delta, FClamp(Dot(Sub(p, a), delta)/lenSqr, 0, 1)))
+}
+
+func seg2segFunc(contacts []*Contact, seg1, seg2 *SegmentShape) int {
+	rsum := seg1.Radius + seg2.Radius
+
+	s, t := closestPointsSegments(seg1.Ta, seg1.Tb, seg2.Ta, seg2.Tb)
+	p1 := Lerp(seg1.Ta, seg1.Tb, s)
+	p2 := Lerp(seg2.Ta, seg2.Tb, t)
+
+	delta := Sub(p2, p1)
+	distSqr := delta.LengthSqr()
+	if distSqr >= rsum*rsum {
+		return 0
+	}
+
+	dist := float32(math.Sqrt(float64(distSqr)))
+
+	var n Vect
+	if dist != 0 {
+		n = Mult(delta, 1/dist)
+	} else {
+		// The center lines intersect, push along the normal of the first segment.
+		n = seg1.Tn
+		center1 := Lerp(seg1.Ta, seg1.Tb, 0.5)
+		center2 := Lerp(seg2.Ta, seg2.Tb, 0.5)
+		if Dot(n, Sub(center2, center1)) < 0 {
+			n = Mult(n, -1)
+		}
+	}
+
+	num := 0
+
+	// Nearly parallel segments rest on each other along their overlap, use two contacts for it.
+	dir1 := Sub(seg1.Tb, seg1.Ta)
+	dir2 := Sub(seg2.Tb, seg2.Ta)
+	len1 := dir1.Length()
+	len2 := dir2.Length()
+	if len1 != 0 && len2 != 0 && FAbs(Cross(dir1, dir2)/(len1*len2)) < 0.1 {
+		t1 := Mult(dir1, 1/len1)
+		sa := Dot(Sub(seg2.Ta, seg1.Ta), t1)
+		sb := Dot(Sub(seg2.Tb, seg1.Ta), t1)
+		lo := FMax(0, FMin(sa, sb))
+		hi := FMin(len1, FMax(sa, sb))
+
+		if hi-lo > 0.01*len1 {
+			for i, param := range [2]float32{lo, hi} {
+				v1 := Add(seg1.Ta, Mult(t1, param))
+				v2 := closestPointOnSegment(seg2.Ta, seg2.Tb, v1)
+
+				d := Dot(Sub(v2, v1), n) - rsum
+				if d < 0 {
+					pos := Add(v1, Mult(n, seg1.Radius+d*0.5))
+					nextContact(contacts, &num).reset(pos, n, d, hashPair(seg1.Shape.Hash(), HashValue(i+1)))
+				}
+			}
+		}
+	}
+
+	if num == 0 {
+		d := dist - rsum
+		pos := Add(p1, Mult(n, seg1.Radius+d*0.5))
+		nextContact(contacts, &num).reset(pos, n, d, hashPair(seg1.Shape.Hash(), 0))
+	}
+
+	return num
+}
+
 func circle2polyFunc(contacts []*Contact, circle *CircleShape, poly *PolygonShape) int {
 
 	axes := poly.TAxes
@@ -0,0 +1,68 @@
+package chipmunk
+
+import (
+	"testing"
+)
+
+func TestSegmentToSegment(t *testing.T) {
+	tests := []struct {
+		name   string
+		a, b   Vect
+		points []Vect
+	}{
+		{"crossing", Vect{0, 1.5}, Vect{0, 10}, []Vect{{0, 0.75}}},
+		{"parallel", Vect{-2, 1.5}, Vect{8, 1.5}, []Vect{{-2, 0.75}, {5, 0.75}}},
+		{"separated", Vect{0, 3}, Vect{0, 10}, nil},
+	}
+	for _, test := range tests {
+		bodyA, bodyB := NewBody(1, 1), NewBody(1, 1)
+		shapeA := NewSegment(Vect{-5, 0}, Vect{5, 0}, 1)
+		shapeB := NewSegment(test.a, test.b, 1)
+		bodyA.AddShape(shapeA)
+		bodyB.AddShape(shapeB)
+		bodyA.UpdateShapes()
+		bodyB.UpdateShapes()
+
+		contacts := make([]*Contact, MaxPoints)
+		for i := range contacts {
+			contacts[i] = &Contact{}
+		}
+		num := collide(contacts, shapeA, shapeB)
+		if num != len(test.points) {
+			t.Errorf("%s: %d contacts, want %d", test.name, num, len(test.points))
+			continue
+		}
+		for i, con := range contacts[:num] {
+			if Dist(con.Position(), test.points[i]) > 1e-4 || Dist(con.Normal(), Vect{0, 1}) > 1e-4 || FAbs(con.dist+0.5) > 1e-4 {
+				t.Errorf("%s: contact %d at %v with normal %v and depth %v", test.name, i, con.Position(), con.Normal(), con.dist)
+			}
+			for _, other := range contacts[:i] {
+				if other.hash == con.hash {
+					t.Errorf("%s: contacts share the hash %v", test.name, con.hash)
+				}
+			}
+		}
+	}
+}
+
+func TestSegmentRestsOnSegment(t *testing.T) {
+	space := NewSpace()
+	space.Gravity = Vect{0, -600}
+	ground := NewBodyStatic()
+	ground.AddShape(NewSegment(Vect{-50, 0}, Vect{50, 0}, 1))
+	space.AddBody(ground)
+
+	// Moment of a rod as long as the capsule with its rounded ends.
+	capsule := NewBody(1, 24*24/12.0)
+	capsule.AddShape(NewSegment(Vect{-10, 0}, Vect{10, 0}, 2))
+	capsule.SetPosition(Vect{0, 20})
+	space.AddBody(capsule)
+
+	stepSpace(space, 120)
+	if p := capsule.Position(); FAbs(p.Y-3) > 0.5 || FAbs(p.X) > 0.5 {
+		t.Fatalf("capsule came to rest at %v, want (0, 3)", p)
+	}
+	if a := capsule.Angle(); FAbs(a) > 0.01 {
+		t.Fatalf("capsule tipped over by %v", a)
+	}
+}
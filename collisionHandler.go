@@ -0,0 +1,105 @@
+package chipmunk
+
+// Collision type of a shape used when picking collision handlers.
+type CollisionType uint
+
+// Matches any collision type when used in a wildcard handler.
+const WildcardCollisionType = ^CollisionType(0)
+
+// Collision callbacks for shapes of the given collision types.
+// The arbiter passed to the callbacks returns the shapes and bodies
+// in the order of TypeA and TypeB from Arbiter.Shapes() and Arbiter.Bodies().
+type CollisionHandler struct {
+	TypeA, TypeB CollisionType
+
+	// Called when two shapes start touching for the first time.
+	// Returning false ignores the collision until they separate.
+	Begin func(arb *Arbiter, space *Space) bool
+	// Called every step while the shapes are touching, before solving.
+	// Returning false ignores the collision for this step.
+	PreSolve func(arb *Arbiter, space *Space) bool
+	// Called every step while the shapes are touching, after solving.
+	PostSolve func(arb *Arbiter, space *Space)
+	// Called when two shapes stop touching.
+	Separate func(arb *Arbiter, space *Space)
+
+	UserData Data
+}
+
+type collisionTypePair struct {
+	a, b CollisionType
+}
+
+// Returns the handler for collisions between shapes of type a and b, creating it if needed.
+func (space *Space) AddCollisionHandler(a, b CollisionType) *CollisionHandler {
+	key := collisionTypePair{a, b}
+	if handler, ok := space.typeHandlers[key]; ok {
+		return handler
+	}
+
+	handler := &CollisionHandler{TypeA: a, TypeB: b}
+	space.typeHandlers[key] = handler
+	return handler
+}
+
+// Returns the handler called for collisions of shapes of type t with shapes of any type
+// that don't have a handler for the pair, creating it if needed.
+func (space *Space) AddWildcardHandler(t CollisionType) *CollisionHandler {
+	if handler, ok := space.wildcardHandlers[t]; ok {
+		return handler
+	}
+
+	handler := &CollisionHandler{TypeA: t, TypeB: WildcardCollisionType}
+	space.wildcardHandlers[t] = handler
+	return handler
+}
+
+// Removes the handler added with AddCollisionHandler(a, b).
+func (space *Space) RemoveCollisionHandler(a, b CollisionType) {
+	delete(space.typeHandlers, collisionTypePair{a, b})
+}
+
+// Removes the handler added with AddWildcardHandler(t).
+func (space *Space) RemoveWildcardHandler(t CollisionType) {
+	delete(space.wildcardHandlers, t)
+}
+
+// Finds the handler for shapes of type a and b.
+// swapped is true if the handler was registered for the types in the opposite order.
+func (space *Space) lookupHandler(a, b CollisionType) (handler *CollisionHandler, swapped bool) {
+	if len(space.typeHandlers) == 0 && len(space.wildcardHandlers) == 0 {
+		return nil, false
+	}
+
+	if handler, ok := space.typeHandlers[collisionTypePair{a, b}]; ok {
+		return handler, false
+	}
+	if handler, ok := space.typeHandlers[collisionTypePair{b, a}]; ok {
+		return handler, true
+	}
+	if handler, ok := space.wildcardHandlers[a]; ok {
+		return handler, false
+	}
+	if handler, ok := space.wildcardHandlers[b]; ok {
+		return handler, true
+	}
+	return nil, false
+}
+
+// Pre-solve callback that turns segment shapes of the handler's TypeA into one-way platforms.
+// Shapes land on a platform from the side its normal points to, which is the left side going from A to B,
+// and pass through it from the other side until they separate.
+//
+//	space.AddWildcardHandler(platformType).PreSolve = chipmunk.OneWayPlatform
+func OneWayPlatform(arb *Arbiter, space *Space) bool {
+	platform, _ := arb.Shapes()
+	segment := platform.GetAsSegment()
+	if segment == nil {
+		return true
+	}
+	if Dot(arb.Normal(), segment.Tn) < 0 {
+		arb.Ignore()
+		return false
+	}
+	return true
+}
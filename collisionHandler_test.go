@@ -0,0 +1,128 @@
+package chipmunk
+
+import (
+	"testing"
+)
+
+func TestCollisionHandlerLookup(t *testing.T) {
+	space := NewSpace()
+	pair := space.AddCollisionHandler(1, 2)
+	wildcard1 := space.AddWildcardHandler(1)
+	wildcard3 := space.AddWildcardHandler(3)
+
+	tests := []struct {
+		a, b    CollisionType
+		handler *CollisionHandler
+		swapped bool
+	}{
+		{1, 2, pair, false},
+		{2, 1, pair, true},
+		{1, 4, wildcard1, false},
+		{4, 1, wildcard1, true},
+		// The handler of the first type wins when both have a wildcard handler.
+		{1, 3, wildcard1, false},
+		{3, 1, wildcard3, false},
+		{2, 4, nil, false},
+	}
+	for _, test := range tests {
+		handler, swapped := space.lookupHandler(test.a, test.b)
+		if handler != test.handler || swapped != test.swapped {
+			t.Errorf("lookupHandler(%d, %d) = %p, %v, want %p, %v", test.a, test.b, handler, swapped, test.handler, test.swapped)
+		}
+	}
+
+	if space.AddCollisionHandler(1, 2) != pair {
+		t.Error("AddCollisionHandler() didn't return the existing handler")
+	}
+	space.RemoveCollisionHandler(1, 2)
+	if handler, _ := space.lookupHandler(1, 2); handler != wildcard1 {
+		t.Error("removed pair handler didn't fall back to the wildcard handler")
+	}
+	space.RemoveWildcardHandler(1)
+	if handler, _ := space.lookupHandler(1, 2); handler != nil {
+		t.Error("removed wildcard handler is still used")
+	}
+}
+
+func TestCollisionHandlerDispatch(t *testing.T) {
+	const (
+		platformType CollisionType = iota + 1
+		ballType
+	)
+	space, platform, ball := newPlatformSpace(NewBBTree, 0)
+	platform.Shapes[0].CollisionType = platformType
+	ball.Shapes[0].CollisionType = ballType
+
+	calls := map[string]int{}
+	var first *Shape
+	record := func(name string) func(arb *Arbiter, space *Space) bool {
+		return func(arb *Arbiter, space *Space) bool {
+			calls[name]++
+			first, _ = arb.Shapes()
+			return true
+		}
+	}
+	pair := space.AddCollisionHandler(platformType, ballType)
+	pair.Begin = record("pair")
+	space.AddWildcardHandler(ballType).Begin = record("wildcard")
+
+	stepSpace(space, 30)
+	if calls["pair"] != 1 || calls["wildcard"] != 0 {
+		t.Fatalf("begin calls = %v, want only the pair handler once", calls)
+	}
+	if first != platform.Shapes[0] {
+		t.Error("Shapes() didn't return the shapes in the order of the pair handler")
+	}
+
+	// Without the pair handler the wildcard handler of the ball gets the collision, with the ball first.
+	space.RemoveCollisionHandler(platformType, ballType)
+	ball.SetPosition(Vect{0, 20})
+	stepSpace(space, 60)
+	if calls["wildcard"] != 1 {
+		t.Fatalf("begin calls = %v, want the wildcard handler once", calls)
+	}
+	if first != ball.Shapes[0] {
+		t.Error("Shapes() didn't return the shape of the wildcard type first")
+	}
+}
+
+// Collision callbacks of a body that count the begin calls and reject the collisions.
+type enterCounter struct {
+	enter int
+}
+
+func (c *enterCounter) CollisionEnter(arbiter *Arbiter) bool {
+	c.enter++
+	return false
+}
+func (c *enterCounter) CollisionPreSolve(arbiter *Arbiter) bool { return true }
+func (c *enterCounter) CollisionPostSolve(arbiter *Arbiter)     {}
+func (c *enterCounter) CollisionExit(arbiter *Arbiter)          {}
+
+func TestCollisionBeginStopsAtFirstRejection(t *testing.T) {
+	space, platform, ball := newPlatformSpace(NewBBTree, 0)
+	platformCallbacks, ballCallbacks := &enterCounter{}, &enterCounter{}
+	platform.CallbackHandler = platformCallbacks
+	ball.CallbackHandler = ballCallbacks
+
+	handler := space.AddWildcardHandler(0)
+	handler.Begin = func(arb *Arbiter, space *Space) bool { return true }
+	stepSpace(space, 30)
+	if platformCallbacks.enter+ballCallbacks.enter != 1 {
+		t.Fatalf("CollisionEnter() called %d and %d times, want only the first body",
+			platformCallbacks.enter, ballCallbacks.enter)
+	}
+	if y := ball.Position().Y; y > -20 {
+		t.Fatalf("rejected ball didn't fall through the platform: %v", y)
+	}
+
+	// A rejecting handler skips the bodies.
+	platformCallbacks.enter, ballCallbacks.enter = 0, 0
+	handler.Begin = func(arb *Arbiter, space *Space) bool { return false }
+	ball.SetPosition(Vect{0, 20})
+	ball.SetVelocity(0, 0)
+	stepSpace(space, 30)
+	if platformCallbacks.enter+ballCallbacks.enter != 0 {
+		t.Fatal("CollisionEnter() called after the handler rejected the collision")
+	}
+}
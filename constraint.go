@@ -9,6 +9,12 @@ type ConstraintCallback interface {
 	CollisionPostSolve(constraint Constraint)
 }
 
+// Optionally implemented by a ConstraintCallback to be notified when the constraint breaks.
+// impulse is the impulse the constraint applied in the step it broke.
+type ConstraintBreakCallback interface {
+	ConstraintBroken(constraint Constraint, impulse float32)
+}
+
 type Constraint interface {
 	Constraint() *BasicConstraint
 	PreSolve()
@@ -27,16 +33,43 @@ type BasicConstraint struct {
 	MaxBias         float32
 	CallbackHandler ConstraintCallback
 	UserData        Data
+
+	// The constraint is removed from the space after a step where it applied
+	// more than BreakImpulse, or more than BreakForce*dt. Both default to infinity.
+	BreakForce   float32
+	BreakImpulse float32
+
+	// Next constraints in the constraint lists of BodyA and BodyB.
+	nextA, nextB Constraint
 }
 
 func NewConstraint(a, b *Body) BasicConstraint {
-	return BasicConstraint{BodyA: a, BodyB: b, MaxForce: Inf, MaxBias: Inf, ErrorBias: errorBias}
+	return BasicConstraint{BodyA: a, BodyB: b, MaxForce: Inf, MaxBias: Inf, ErrorBias: errorBias, BreakForce: Inf, BreakImpulse: Inf}
 }
 
 func (this *BasicConstraint) Constraint() *BasicConstraint {
 	return this
 }
 
+// Returns the next constraint in the constraint list of body.
+func (this *BasicConstraint) next(body *Body) Constraint {
+	if this.BodyA == body {
+		return this.nextA
+	}
+	return this.nextB
+}
+
+// Returns true if impulse applied during a step of dt exceeds the break thresholds.
+func (this *BasicConstraint) breaks(impulse, dt float32) bool {
+	return impulse > this.BreakImpulse || impulse > this.BreakForce*dt
+}
+
+func (this *BasicConstraint) broken(constraint Constraint, impulse float32) {
+	if callback, ok := this.CallbackHandler.(ConstraintBreakCallback); ok {
+		callback.ConstraintBroken(constraint, impulse)
+	}
+}
+
 func (this *BasicConstraint) PreStep(dt float32) {
 	panic("empty constraint")
 }
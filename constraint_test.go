@@ -0,0 +1,244 @@
+package chipmunk
+
+import (
+	"testing"
+)
+
+// Returns a space with the gravity, a static body at the origin and a dynamic body at pos.
+func newJointTestSpace(gravity, pos Vect) (space *Space, static, body *Body) {
+	space = NewSpace()
+	space.Gravity = gravity
+	static = NewBodyStatic()
+	space.AddBody(static)
+	body = NewBody(1, 1)
+	body.SetPosition(pos)
+	space.AddBody(body)
+	return
+}
+
+func TestPinJointKeepsDistance(t *testing.T) {
+	space, static, bob := newJointTestSpace(Vect{0, -100}, Vect{10, 0})
+	space.AddConstraint(NewPinJoint(static, bob, Vector_Zero, Vector_Zero))
+
+	for i := 0; i < 120; i++ {
+		space.Step(1.0 / 60.0)
+		if d := Length(bob.Position()); FAbs(d-10) > 0.25 {
+			t.Fatalf("pendulum is %v away after %d steps, want 10", d, i+1)
+		}
+	}
+	if y := bob.Position().Y; y > -5 {
+		t.Fatalf("pendulum didn't swing down: %v", y)
+	}
+}
+
+func TestSlideJointLimits(t *testing.T) {
+	space, static, bob := newJointTestSpace(Vect{0, -100}, Vect{0, -3})
+	space.AddConstraint(NewSlideJoint(static, bob, Vector_Zero, Vector_Zero, 2, 5))
+
+	stepSpace(space, 120)
+	if d := Length(bob.Position()); FAbs(d-5) > 0.1 {
+		t.Fatalf("hanging body is %v away, want the maximum of 5", d)
+	}
+
+	// Pushed towards the anchor it stops at the minimum.
+	space.Gravity = Vect{0, 100}
+	stepSpace(space, 120)
+	if d := Length(bob.Position()); FAbs(d-2) > 0.1 {
+		t.Fatalf("pushed body is %v away, want the minimum of 2", d)
+	}
+}
+
+func TestGrooveJointSlides(t *testing.T) {
+	space, static, bob := newJointTestSpace(Vect{100, -100}, Vector_Zero)
+	space.AddConstraint(NewGrooveJoint(static, bob, Vect{-10, 0}, Vect{10, 0}, Vector_Zero))
+
+	stepSpace(space, 120)
+	if p := bob.Position(); FAbs(p.X-10) > 0.1 || FAbs(p.Y) > 0.1 {
+		t.Fatalf("body is at %v, want the end of the groove at (10, 0)", p)
+	}
+}
+
+func TestDampedRotarySpringSettles(t *testing.T) {
+	space, static, wheel := newJointTestSpace(Vector_Zero, Vector_Zero)
+	wheel.SetAngle(1)
+	space.AddConstraint(NewDampedRotarySpring(static, wheel, 0, 100, 5))
+
+	stepSpace(space, 10)
+	if wheel.AngularVelocity() >= 0 {
+		t.Fatal("spring didn't turn the wheel back")
+	}
+	stepSpace(space, 300)
+	if a := wheel.Angle(); FAbs(a) > 0.05 {
+		t.Fatalf("wheel settled at %v, want the rest angle 0", a)
+	}
+}
+
+func TestRotaryLimitJointStops(t *testing.T) {
+	space, static, wheel := newJointTestSpace(Vector_Zero, Vector_Zero)
+	space.AddConstraint(NewRotaryLimitJoint(static, wheel, -0.5, 0.5))
+
+	// The wheel may pass a limit by one step of its speed before it's pushed back.
+	for _, w := range []float32{10, -10} {
+		wheel.SetAngularVelocity(w)
+		reached := float32(0)
+		for i := 0; i < 60; i++ {
+			space.Step(1.0 / 60.0)
+			reached = FMax(reached, FAbs(wheel.Angle()))
+		}
+		if reached < 0.5 || reached > 0.5+10.0/60 {
+			t.Fatalf("wheel turning at %v reached %v, want the limit 0.5", w, reached)
+		}
+	}
+}
+
+func TestRatchetJointBlocksReverse(t *testing.T) {
+	space, static, wheel := newJointTestSpace(Vector_Zero, Vector_Zero)
+	space.AddConstraint(NewRatchetJoint(static, wheel, 0, 0.5))
+
+	wheel.SetAngularVelocity(5)
+	stepSpace(space, 30)
+	forward := wheel.Angle()
+	if forward < 2 {
+		t.Fatalf("ratchet held the wheel back at %v", forward)
+	}
+
+	// Turning back stops at the last notch.
+	wheel.SetAngularVelocity(-5)
+	stepSpace(space, 30)
+	notch := float32(int(forward/0.5)) * 0.5
+	if a := wheel.Angle(); a < notch-0.05 {
+		t.Fatalf("wheel turned back to %v past the notch at %v", a, notch)
+	}
+}
+
+func TestGearJointRatio(t *testing.T) {
+	space, _, driver := newJointTestSpace(Vector_Zero, Vector_Zero)
+	driven := NewBody(1, 1)
+	space.AddBody(driven)
+	space.AddConstraint(NewGearJoint(driver, driven, 0, 2))
+
+	driver.SetAngularVelocity(4)
+	stepSpace(space, 60)
+	if w := driven.AngularVelocity() * 2; FAbs(w-driver.AngularVelocity()) > 0.01 {
+		t.Fatalf("driven wheel turns at %v, want half of %v", driven.AngularVelocity(), driver.AngularVelocity())
+	}
+	if a := driven.Angle() * 2; FAbs(a-driver.Angle()) > 0.05 {
+		t.Fatalf("driven wheel is at %v, want half of %v", driven.Angle(), driver.Angle())
+	}
+}
+
+func TestSimpleMotorReachesRate(t *testing.T) {
+	space, static, wheel := newJointTestSpace(Vector_Zero, Vector_Zero)
+	space.AddConstraint(NewSimpleMotor(static, wheel, 3))
+
+	// Like Chipmunk the motor drives the relative rate of BodyA to BodyB.
+	stepSpace(space, 10)
+	if w := wheel.AngularVelocity(); FAbs(w+3) > 0.01 {
+		t.Fatalf("wheel turns at %v, want -3", w)
+	}
+
+	// A limited force takes longer to get there.
+	space, static, wheel = newJointTestSpace(Vector_Zero, Vector_Zero)
+	motor := NewSimpleMotor(static, wheel, 3)
+	motor.MaxForce = 6
+	space.AddConstraint(motor)
+	stepSpace(space, 15)
+	if w := wheel.AngularVelocity(); FAbs(w+1.5) > 0.01 {
+		t.Fatalf("wheel with a limited motor turns at %v after 0.25s, want -1.5", w)
+	}
+}
+
+// Constraint callbacks that record the broken constraints and can remove them.
+type breakRecorder struct {
+	space    *Space
+	remove   bool
+	broken   []Constraint
+	impulses []float32
+}
+
+func (r *breakRecorder) CollisionPreSolve(constraint Constraint)  {}
+func (r *breakRecorder) CollisionPostSolve(constraint Constraint) {}
+func (r *breakRecorder) ConstraintBroken(constraint Constraint, impulse float32) {
+	r.broken = append(r.broken, constraint)
+	r.impulses = append(r.impulses, impulse)
+	if r.remove {
+		r.space.RemoveConstraint(constraint)
+	}
+}
+
+func TestConstraintBreakImpulse(t *testing.T) {
+	// Hanging still, the pin applies 100 * 1/60 each step.
+	space, static, bob := newJointTestSpace(Vect{0, -100}, Vect{0, -10})
+	pin := NewPinJoint(static, bob, Vector_Zero, Vector_Zero)
+	pin.BreakImpulse = 5
+	recorder := &breakRecorder{space: space}
+	pin.CallbackHandler = recorder
+	space.AddConstraint(pin)
+
+	stepSpace(space, 30)
+	if len(recorder.broken) != 0 || pin.space != space {
+		t.Fatal("pin broke under the weight of the body")
+	}
+
+	bob.SetVelocity(0, -100)
+	space.Step(1.0 / 60.0)
+	if len(recorder.broken) != 1 || recorder.broken[0] != pin || recorder.impulses[0] <= 5 {
+		t.Fatalf("ConstraintBroken() got %v with the impulses %v", recorder.broken, recorder.impulses)
+	}
+	if pin.space != nil || len(space.Constraints) != 0 {
+		t.Fatal("broken pin is still in the space")
+	}
+	stepSpace(space, 30)
+	if y := bob.Position().Y; y > -15 {
+		t.Fatalf("body held by the broken pin is at %v", y)
+	}
+}
+
+func TestConstraintBreakForce(t *testing.T) {
+	space, static, bob := newJointTestSpace(Vect{0, -100}, Vect{0, -10})
+	pin := NewPinJoint(static, bob, Vector_Zero, Vector_Zero)
+	pin.BreakForce = 150
+	space.AddConstraint(pin)
+
+	stepSpace(space, 30)
+	if pin.space != space {
+		t.Fatal("pin broke under a force below BreakForce")
+	}
+	space.Gravity = Vect{0, -200}
+	stepSpace(space, 2)
+	if pin.space != nil {
+		t.Fatalf("pin with an impulse of %v didn't break", pin.Impulse())
+	}
+}
+
+func TestConstraintsBreakTogether(t *testing.T) {
+	for _, remove := range []bool{false, true} {
+		space, static, _ := newJointTestSpace(Vect{0, -100}, Vect{0, -10})
+		recorder := &breakRecorder{space: space, remove: remove}
+		var chain []*Body
+		prev := static
+		for i := 1; i <= 3; i++ {
+			body := NewBody(1, 1)
+			body.SetPosition(Vect{0, float32(-10 * i)})
+			space.AddBody(body)
+			pin := NewPinJoint(prev, body, Vector_Zero, Vector_Zero)
+			pin.BreakImpulse = 0.1
+			pin.CallbackHandler = recorder
+			space.AddConstraint(pin)
+			chain = append(chain, body)
+			prev = body
+		}
+
+		// The callback may remove the constraint itself.
+		space.Step(1.0 / 60.0)
+		if len(recorder.broken) != 3 || len(space.Constraints) != 0 {
+			t.Fatalf("removing in the callback %v: %d constraints broke and %d are left",
+				remove, len(recorder.broken), len(space.Constraints))
+		}
+		for _, body := range chain {
+			body.EachConstraint(func(constraint Constraint) {
+				t.Fatal("broken constraint is still attached to a body")
+			})
+		}
+	}
+}
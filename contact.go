@@ -1,6 +1,7 @@
 package chipmunk
 
 type Contact struct {
+	// Position and normal of the contact, the normal points from the arbiter's ShapeA to ShapeB.
 	p, n Vect
 	dist float32
 
@@ -24,6 +25,8 @@ func (con *Contact) reset(pos, norm Vect, dist float32, hash HashValue) {
 	con.jBias = 0.0
 }
 
+// Returns the contact normal pointing from the arbiter's ShapeA to ShapeB.
+// Use Arbiter.Normal or Arbiter.ShapesFor for the normal in the order of the handler or a body.
 func (con *Contact) Normal() Vect {
 	return con.n
 }
@@ -31,3 +34,19 @@ func (con *Contact) Normal() Vect {
 func (con *Contact) Position() Vect {
 	return con.p
 }
+
+// Contact points between two shapes.
+type ContactPointSet struct {
+	// Number of points in the set.
+	Count  int
+	Points [MaxPoints]ContactPoint
+}
+
+type ContactPoint struct {
+	// Position of the contact.
+	Point Vect
+	// Normal of the contact, pointing from the first shape to the second.
+	Normal Vect
+	// Penetration distance of the shapes, negative when they overlap.
+	Dist float32
+}
@@ -0,0 +1,104 @@
+package chipmunk
+
+// Sweeps the shapes of a bullet body from where they were at the start of the step to its new position
+// and moves the body back to the first time of impact, leaving just enough overlap for the collision
+// to be picked up this step. A bullet that hits a moving body moves along with it after the impact. The overlap is at most half the radius of the swept circle so the center
+// of a small shape never passes a thin wall.
+// Must be called after the body was integrated and before its shapes are updated.
+//
+// The shapes are swept as circles around their center, non circle shapes use the largest circle that
+// fits in them, and the rotation over the step is ignored. Other shapes are tested where they were
+// at the start of the step, with the bullet swept relative to the straight motion of their body.
+func (space *Space) sweepBullet(body *Body) {
+	delta := Sub(body.p, body.prevP)
+	length := Length(delta)
+	if length == 0 {
+		return
+	}
+
+	alpha := float32(1)
+	hit := false
+	// How far to move past the time of impact.
+	overlap := float32(0)
+	// Motion of the body that was hit and the length of the sweep relative to it.
+	hitDelta, hitLength := Vector_Zero, float32(0)
+	xf := NewTransform(body.prevPosition(), body.prevA)
+
+	for _, shape := range body.Shapes {
+		if shape.IsSensor || shape.Body != body {
+			continue
+		}
+
+		// The shape may not have been updated since the body was moved by hand.
+		shape.BB = shape.ShapeClass.update(xf)
+		start, r := sweepCircle(shape)
+		end := Add(start, delta)
+
+		bb := NewAABB(start.X-r, start.Y-r, start.X+r, start.Y+r)
+		bb = Combine(bb, NewAABB(end.X-r, end.Y-r, end.X+r, end.Y+r))
+
+		queryFunc := func(a, b Indexable) {
+			other := b.Shape()
+			if other.Body == body || other.IsSensor || queryRejectShapes(shape, other) {
+				return
+			}
+			if space.ShouldCollide != nil && !space.ShouldCollide(shape, other) {
+				return
+			}
+
+			// Sweep in the frame of a moving body so the bullet hits it where it is, not where it was.
+			otherDelta := Vector_Zero
+			if other.Body.movedLastStep() {
+				otherDelta = Sub(other.Body.p, other.Body.prevP)
+			}
+			relEnd := Sub(end, otherDelta)
+
+			var info SegmentQueryInfo
+			if other.segmentQuery(start, relEnd, r, &info) && info.Alpha < alpha {
+				alpha = info.Alpha
+				overlap = FMin(space.collisionSlop, r*0.5)
+				hitDelta, hitLength = otherDelta, Dist(start, relEnd)
+				hit = true
+			}
+		}
+
+		space.staticShapes.Query(shape, bb, queryFunc)
+		space.activeShapes.Query(shape, bb, queryFunc)
+	}
+
+	if hit {
+		if hitLength > 0 {
+			alpha = FMin(alpha+overlap/hitLength, 1)
+		}
+		body.p = Add(body.prevP, Add(Mult(delta, alpha), Mult(hitDelta, 1-alpha)))
+	}
+}
+
+// Returns the center and radius of the circle used to sweep the shape.
+func sweepCircle(shape *Shape) (center Vect, r float32) {
+	switch class := shape.ShapeClass.(type) {
+	case *CircleShape:
+		return class.Tc, class.Radius
+	case *SegmentShape:
+		return Lerp(class.Ta, class.Tb, 0.5), class.Radius
+	case *PolygonShape:
+		return polygonSweepCircle(class)
+	case *BoxShape:
+		return polygonSweepCircle(class.Polygon)
+	}
+	return shape.BB.Center(), 0
+}
+
+// Returns the center of the polygon and the radius of the largest circle around it inside the polygon.
+func polygonSweepCircle(poly *PolygonShape) (center Vect, r float32) {
+	for _, v := range poly.TVerts {
+		center = Add(center, v)
+	}
+	center = Mult(center, 1/float32(poly.NumVerts))
+
+	r = Inf
+	for _, axis := range poly.TAxes {
+		r = FMin(r, axis.D-Dot(axis.N, center))
+	}
+	return center, FMax(r, 0)
+}
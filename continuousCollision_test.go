@@ -0,0 +1,90 @@
+package chipmunk
+
+import (
+	"testing"
+)
+
+func TestBulletSmallCircleThinWall(t *testing.T) {
+	for _, radius := range []float32{0.2, 0.4, 1, 5} {
+		space := NewSpace()
+		wall := NewBodyStatic()
+		wall.AddShape(NewSegment(Vect{10, -100}, Vect{10, 100}, 0))
+		space.AddBody(wall)
+
+		bullet := NewBody(1, MomentForCircle(1, 0, radius, Vector_Zero))
+		bullet.AddShape(NewCircle(Vector_Zero, radius))
+		bullet.Shapes[0].SetElasticity(1)
+		bullet.Bullet = true
+		bullet.SetVelocity(3000, 0)
+		space.AddBody(bullet)
+
+		stepSpace(space, 10)
+		if x := bullet.Position().X; x > 10 {
+			t.Errorf("bullet with radius %v tunneled through the wall to %v", radius, x)
+		}
+		if vx := bullet.Velocity().X; vx >= 0 {
+			t.Errorf("bullet with radius %v didn't bounce off the wall: velocity %v", radius, vx)
+		}
+	}
+}
+
+func TestBulletShapesThinWall(t *testing.T) {
+	triangle := Vertices{{-2, -2}, {-2, 2}, {2, 0}}
+	tests := []struct {
+		name  string
+		shape *Shape
+	}{
+		{"box", NewBox(Vector_Zero, 4, 4)},
+		{"polygon", NewPolygon(triangle, Vector_Zero)},
+		// The sweep uses the circle at the middle of the segment.
+		{"segment", NewSegment(Vect{0, -3}, Vect{0, 3}, 0.5)},
+	}
+	for _, test := range tests {
+		space := NewSpace()
+		wall := NewBodyStatic()
+		wall.AddShape(NewSegment(Vect{10, -100}, Vect{10, 100}, 0))
+		space.AddBody(wall)
+
+		bullet := NewBody(1, 10)
+		bullet.AddShape(test.shape)
+		test.shape.SetElasticity(1)
+		bullet.Bullet = true
+		bullet.SetVelocity(3000, 0)
+		space.AddBody(bullet)
+
+		stepSpace(space, 10)
+		if x := bullet.Position().X; x > 10 {
+			t.Errorf("%s bullet tunneled through the wall to %v", test.name, x)
+		}
+		if vx := bullet.Velocity().X; vx >= 0 {
+			t.Errorf("%s bullet didn't bounce off the wall: velocity %v", test.name, vx)
+		}
+	}
+}
+
+func TestBulletMovingTarget(t *testing.T) {
+	space := NewSpace()
+	target := NewBody(100, MomentForSegment(100, Vect{0, -50}, Vect{0, 50}, 0))
+	target.AddShape(NewSegment(Vect{0, -50}, Vect{0, 50}, 0))
+	target.SetPosition(Vect{20, 0})
+	target.SetVelocity(1200, 0)
+	space.AddBody(target)
+
+	bullet := NewBody(1, MomentForCircle(1, 0, 0.5, Vector_Zero))
+	bullet.AddShape(NewCircle(Vector_Zero, 0.5))
+	bullet.Bullet = true
+	bullet.SetVelocity(3000, 0)
+	space.AddBody(bullet)
+
+	// The bullet catches up with the thin target and hits it where it is at the end of the step
+	// instead of stopping where it was at the start.
+	for i := 0; i < 10; i++ {
+		space.Step(1.0 / 60.0)
+		if bullet.Position().X > target.Position().X {
+			t.Fatalf("bullet at %v passed the target at %v", bullet.Position().X, target.Position().X)
+		}
+	}
+	if vb, vt := bullet.Velocity().X, target.Velocity().X; vb > vt {
+		t.Fatalf("bullet moves at %v behind the target moving at %v, it never hit it", vb, vt)
+	}
+}
@@ -0,0 +1,77 @@
+package chipmunk
+
+import (
+	"math"
+)
+
+// Like a DampedSpring, but works in an angular fashion.
+type DampedRotarySpring struct {
+	BasicConstraint
+
+	RestAngle        float32
+	Stiffness        float32
+	Damping          float32
+	SpringTorqueFunc func(*DampedRotarySpring, float32) float32
+
+	targetWRN float32
+	wCoef     float32
+
+	iSum float32
+	jAcc float32
+}
+
+func defaultSpringTorque(spring *DampedRotarySpring, relativeAngle float32) float32 {
+	return (relativeAngle - spring.RestAngle) * spring.Stiffness
+}
+
+func NewDampedRotarySpring(a, b *Body, restAngle, stiffness, damping float32) *DampedRotarySpring {
+	return &DampedRotarySpring{
+		BasicConstraint:  NewConstraint(a, b),
+		RestAngle:        restAngle,
+		Stiffness:        stiffness,
+		Damping:          damping,
+		SpringTorqueFunc: defaultSpringTorque,
+	}
+}
+
+func (spring *DampedRotarySpring) PreStep(dt float32) {
+	a := spring.BodyA
+	b := spring.BodyB
+
+	moment := a.i_inv + b.i_inv
+	spring.iSum = 1 / moment
+
+	spring.wCoef = float32(1.0 - math.Exp(float64(-spring.Damping*dt*moment)))
+	spring.targetWRN = 0
+
+	// apply spring torque
+	jSpring := spring.SpringTorqueFunc(spring, a.a-b.a) * dt
+	spring.jAcc = jSpring
+
+	a.w -= jSpring * a.i_inv
+	b.w += jSpring * b.i_inv
+}
+
+func (spring *DampedRotarySpring) ApplyCachedImpulse(_ float32) {}
+
+func (spring *DampedRotarySpring) ApplyImpulse() {
+	a := spring.BodyA
+	b := spring.BodyB
+
+	// compute relative velocity
+	wrn := a.w - b.w
+
+	// compute velocity loss from drag
+	wDamp := (spring.targetWRN - wrn) * spring.wCoef
+	spring.targetWRN = wrn + wDamp
+
+	jDamp := wDamp * spring.iSum
+	spring.jAcc += jDamp
+
+	a.w += jDamp * a.i_inv
+	b.w -= jDamp * b.i_inv
+}
+
+func (spring *DampedRotarySpring) Impulse() float32 {
+	return spring.jAcc
+}
@@ -19,6 +19,8 @@ type DampedSpring struct {
 	r1, r2 Vect
 	nMass  float32
 	n      Vect
+
+	jAcc float32
 }
 
 func defaultSpringForce(spring *DampedSpring, dist float32) float32 {
@@ -43,8 +45,8 @@ func (spring *DampedSpring) PreStep(dt float32) {
 	a := spring.BodyA
 	b := spring.BodyB
 
-	spring.r1 = RotateVect(spring.Anchor1, Rotation{a.rot.X, a.rot.Y})
-	spring.r2 = RotateVect(spring.Anchor2, Rotation{a.rot.X, a.rot.Y})
+	spring.r1 = RotateVect(Sub(spring.Anchor1, a.cog), Rotation{a.rot.X, a.rot.Y})
+	spring.r2 = RotateVect(Sub(spring.Anchor2, b.cog), Rotation{b.rot.X, b.rot.Y})
 
 	delta := Sub(Add(b.p, spring.r2), Add(a.p, spring.r1))
 	dist := Length(delta)
@@ -60,7 +62,8 @@ func (spring *DampedSpring) PreStep(dt float32) {
 	spring.vCoef = float32(1.0 - math.Exp(float64(-spring.Damping*dt*k)))
 
 	fSpring := spring.SpringForceFunc(spring, dist)
-	apply_impulses(a, b, spring.r1, spring.r2, Mult(spring.n, fSpring*dt))
+	spring.jAcc = fSpring * dt
+	apply_impulses(a, b, spring.r1, spring.r2, Mult(spring.n, spring.jAcc))
 }
 
 func (spring *DampedSpring) ApplyCachedImpulse(_ float32) {}
@@ -78,9 +81,12 @@ func (spring *DampedSpring) ApplyImpulse() {
 	vDamp := (spring.targetVRN - vrn) * spring.vCoef
 	spring.targetVRN = vrn + vDamp
 
-	apply_impulses(a, b, spring.r1, spring.r2, Mult(spring.n, vDamp*spring.nMass))
+	jDamp := vDamp * spring.nMass
+	spring.jAcc += jDamp
+
+	apply_impulses(a, b, spring.r1, spring.r2, Mult(spring.n, jDamp))
 }
 
 func (spring *DampedSpring) Impulse() float32 {
-	return 0
+	return spring.jAcc
 }
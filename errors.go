@@ -0,0 +1,42 @@
+package chipmunk
+
+import (
+	"errors"
+)
+
+// Errors returned when adding or removing objects from a space.
+var (
+	// The object is nil.
+	ErrNilObject = errors.New("chipmunk: nil object")
+	// The body, shape or constraint is already added to a space.
+	ErrAlreadyInSpace = errors.New("chipmunk: object is already added to a space")
+	// The body, shape or constraint is not added to this space.
+	ErrNotInSpace = errors.New("chipmunk: object is not added to this space")
+	// The space is in the middle of a step or a query, use Space.AddPostStepCallback() instead.
+	ErrSpaceLocked = errors.New("chipmunk: operation cannot be done safely during a call to Space.Step() or during a query, use a post-step callback")
+	// The shape or constraint is not attached to a body.
+	ErrNoBody = errors.New("chipmunk: shape or constraint has no body")
+	// The mass of a dynamic body is NaN, zero or negative,
+	// or the mass or density of a shape is NaN, negative or infinite.
+	ErrInvalidMass = errors.New("chipmunk: body mass must be positive and not NaN")
+	// The moment of inertia of a dynamic body is NaN, zero or negative.
+	ErrInvalidMoment = errors.New("chipmunk: body moment must be positive and not NaN")
+	// The position, velocity or angle of a body is NaN.
+	ErrInvalidPosition = errors.New("chipmunk: body position, velocity or angle is NaN")
+	// The body type is not one of the BodyType constants.
+	ErrInvalidBodyType = errors.New("chipmunk: invalid body type")
+)
+
+// Errors returned when encoding or decoding a space.
+var (
+	// The shape or constraint type can't be serialized.
+	ErrUnsupportedType = errors.New("chipmunk: type can't be serialized")
+	// The data was written by an unknown version of the format.
+	ErrUnsupportedVersion = errors.New("chipmunk: unsupported serialization version")
+	// The data is corrupted or not a serialized space.
+	ErrInvalidData = errors.New("chipmunk: invalid serialized data")
+	// Two bodies have the same ID.
+	ErrDuplicateID = errors.New("chipmunk: duplicate body ID")
+	// A constraint references a body ID that isn't in the data.
+	ErrUnknownID = errors.New("chipmunk: unknown body ID")
+)
@@ -33,8 +33,8 @@ func drawCircle(radius float64, sides int) {
 	gl.End()
 }
 
-// OpenGL draw function
-func draw() {
+// OpenGL draw function, alpha interpolates the balls between the last two physics steps
+func draw(alpha float32) {
 	gl.Clear(gl.COLOR_BUFFER_BIT)
 	gl.Enable(gl.BLEND)
 	gl.Enable(gl.POINT_SMOOTH)
@@ -58,8 +58,8 @@ func draw() {
 	// draw balls
 	for _, ball := range balls {
 		gl.PushMatrix()
-		pos := ball.Body.Position()
-		rot := ball.Body.Angle() * chipmunk.DegreeConst
+		pos := ball.Body.InterpolatedPosition(alpha)
+		rot := ball.Body.InterpolatedAngle(alpha) * chipmunk.DegreeConst
 		gl.Translatef(float32(pos.X), float32(pos.Y), 0.0)
 		gl.Rotatef(float32(rot), 0, 0, 1)
 		drawCircle(float64(ballRadius), 60)
@@ -81,9 +81,9 @@ func addBall() {
 	balls = append(balls, ball)
 }
 
-// step advances the physics engine and cleans up any balls that are off-screen
-func step(dt float32) {
-	space.Step(float32(dt))
+// step advances the physics engine in fixed steps and cleans up any balls that are off-screen
+func step(elapsed float32) (alpha float32) {
+	alpha = space.Update(elapsed)
 
 	for i := 0; i < len(balls); i++ {
 		p := balls[i].Body.Position()
@@ -94,6 +94,7 @@ func step(dt float32) {
 			i-- // consider same index again
 		}
 	}
+	return alpha
 }
 
 // createBodies sets up the chipmunk space and static bodies
@@ -160,14 +161,17 @@ func main() {
 
 	ticksToNextBall := 10
 	ticker := time.NewTicker(time.Second / 60)
+	last := time.Now()
 	for !window.ShouldClose() {
 		ticksToNextBall--
 		if ticksToNextBall == 0 {
 			ticksToNextBall = rand.Intn(100) + 1
 			addBall()
 		}
-		draw()
-		step(1.0 / 60.0)
+		now := time.Now()
+		alpha := step(float32(now.Sub(last).Seconds()))
+		last = now
+		draw(alpha)
 		window.SwapBuffers()
 		glfw.PollEvents()
 
@@ -0,0 +1,65 @@
+package chipmunk
+
+// Keeps the angular velocity ratio of two bodies constant.
+type GearJoint struct {
+	BasicConstraint
+	Phase, Ratio float32
+
+	ratioInv float32
+	iSum     float32
+	bias     float32
+
+	jAcc, jMax float32
+}
+
+func NewGearJoint(a, b *Body, phase, ratio float32) *GearJoint {
+	return &GearJoint{
+		BasicConstraint: NewConstraint(a, b),
+		Phase:           phase,
+		Ratio:           ratio,
+	}
+}
+
+func (this *GearJoint) PreStep(dt float32) {
+	a, b := this.BodyA, this.BodyB
+
+	this.ratioInv = 1 / this.Ratio
+
+	// calculate moment of inertia coefficient.
+	this.iSum = 1 / (a.i_inv*this.ratioInv + this.Ratio*b.i_inv)
+
+	// compute max impulse
+	this.jMax = this.MaxForce * dt
+
+	// calculate bias velocity
+	this.bias = FClamp(-bias_coef(this.ErrorBias, dt)*(b.a*this.Ratio-a.a-this.Phase)/dt, -this.MaxBias, this.MaxBias)
+}
+
+func (this *GearJoint) ApplyCachedImpulse(dt_coef float32) {
+	a, b := this.BodyA, this.BodyB
+
+	j := this.jAcc * dt_coef
+	a.w -= j * a.i_inv * this.ratioInv
+	b.w += j * b.i_inv
+}
+
+func (this *GearJoint) ApplyImpulse() {
+	a, b := this.BodyA, this.BodyB
+
+	// compute relative rotational velocity
+	wr := b.w*this.Ratio - a.w
+
+	// compute normal impulse
+	j := (this.bias - wr) * this.iSum
+	jOld := this.jAcc
+	this.jAcc = FClamp(jOld+j, -this.jMax, this.jMax)
+	j = this.jAcc - jOld
+
+	// apply impulse
+	a.w -= j * a.i_inv * this.ratioInv
+	b.w += j * b.i_inv
+}
+
+func (this *GearJoint) Impulse() float32 {
+	return FAbs(this.jAcc)
+}
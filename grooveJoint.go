@@ -0,0 +1,99 @@
+package chipmunk
+
+// Lets the anchor of the second body slide along a groove on the first body.
+type GrooveJoint struct {
+	BasicConstraint
+	// The start/end points of the groove relative to the first body.
+	GrooveA, GrooveB Vect
+	Anchor2          Vect
+
+	grooveTn Vect
+	clamp    float32
+
+	r1, r2 Vect
+	k1, k2 Vect
+
+	jAcc    Vect
+	jMaxLen float32
+	bias    Vect
+}
+
+func NewGrooveJoint(a, b *Body, grooveA, grooveB, anchor2 Vect) *GrooveJoint {
+	return &GrooveJoint{
+		BasicConstraint: NewConstraint(a, b),
+		GrooveA:         grooveA,
+		GrooveB:         grooveB,
+		Anchor2:         anchor2,
+	}
+}
+
+func (this *GrooveJoint) PreStep(dt float32) {
+	a, b := this.BodyA, this.BodyB
+
+	// calculate endpoints in worldspace
+	ta := a.LocalToWorld(this.GrooveA)
+	tb := a.LocalToWorld(this.GrooveB)
+
+	// calculate axis
+	n := RotateVect(Perp(Normalize(Sub(this.GrooveB, this.GrooveA))), Rotation{a.rot.X, a.rot.Y})
+	d := Dot(ta, n)
+
+	this.grooveTn = n
+	this.r2 = RotateVect(Sub(this.Anchor2, b.cog), Rotation{b.rot.X, b.rot.Y})
+
+	// calculate tangential distance along the axis of r2
+	td := Cross(Add(b.p, this.r2), n)
+	// calculate clamping factor and r2
+	if td <= Cross(ta, n) {
+		this.clamp = 1
+		this.r1 = Sub(ta, a.p)
+	} else if td >= Cross(tb, n) {
+		this.clamp = -1
+		this.r1 = Sub(tb, a.p)
+	} else {
+		this.clamp = 0
+		this.r1 = Sub(Add(Mult(Perp(n), -td), Mult(n, d)), a.p)
+	}
+
+	// Calculate mass tensor
+	k_tensor(a, b, this.r1, this.r2, &this.k1, &this.k2)
+
+	// compute max impulse
+	this.jMaxLen = this.MaxForce * dt
+
+	// calculate bias velocity
+	delta := Sub(Add(b.p, this.r2), Add(a.p, this.r1))
+	this.bias = Clamp(Mult(delta, -bias_coef(this.ErrorBias, dt)/dt), this.MaxBias)
+}
+
+func (this *GrooveJoint) ApplyCachedImpulse(dt_coef float32) {
+	apply_impulses(this.BodyA, this.BodyB, this.r1, this.r2, Mult(this.jAcc, dt_coef))
+}
+
+func (this *GrooveJoint) grooveConstrain(j Vect) Vect {
+	n := this.grooveTn
+	jClamp := j
+	if this.clamp*Cross(j, n) <= 0 {
+		jClamp = Mult(n, Dot(j, n)/Dot(n, n))
+	}
+	return Clamp(jClamp, this.jMaxLen)
+}
+
+func (this *GrooveJoint) ApplyImpulse() {
+	a, b := this.BodyA, this.BodyB
+
+	// compute impulse
+	vr := relative_velocity(a, b, this.r1, this.r2)
+
+	j := mult_k(Sub(this.bias, vr), this.k1, this.k2)
+	jOld := this.jAcc
+	this.jAcc = this.grooveConstrain(Add(jOld, j))
+	j = Sub(this.jAcc, jOld)
+
+	// apply impulse
+	apply_impulses(a, b, this.r1, this.r2, j)
+}
+
+func (this *GrooveJoint) Impulse() float32 {
+	return Length(this.jAcc)
+}
@@ -0,0 +1,79 @@
+package chipmunk
+
+// Keeps the anchor points of two bodies at a fixed distance.
+type PinJoint struct {
+	BasicConstraint
+	Anchor1, Anchor2 Vect
+	// The distance the joint keeps between the anchors.
+	Dist float32
+
+	r1, r2 Vect
+	n      Vect
+	nMass  float32
+
+	jnAcc, jnMax float32
+	bias         float32
+}
+
+// Creates a new PinJoint. The distance is calculated from the current positions of the bodies.
+func NewPinJoint(a, b *Body, anchor1, anchor2 Vect) *PinJoint {
+	p1 := a.LocalToWorld(anchor1)
+	p2 := b.LocalToWorld(anchor2)
+
+	return &PinJoint{
+		BasicConstraint: NewConstraint(a, b),
+		Anchor1:         anchor1,
+		Anchor2:         anchor2,
+		Dist:            Dist(p1, p2),
+	}
+}
+
+func (this *PinJoint) PreStep(dt float32) {
+	a, b := this.BodyA, this.BodyB
+
+	this.r1 = RotateVect(Sub(this.Anchor1, a.cog), Rotation{a.rot.X, a.rot.Y})
+	this.r2 = RotateVect(Sub(this.Anchor2, b.cog), Rotation{b.rot.X, b.rot.Y})
+
+	delta := Sub(Add(b.p, this.r2), Add(a.p, this.r1))
+	dist := Length(delta)
+	if dist != 0 {
+		this.n = Mult(delta, 1/dist)
+	} else {
+		this.n = Vector_Zero
+	}
+
+	// calculate mass normal
+	this.nMass = 1 / k_scalar(a, b, this.r1, this.r2, this.n)
+
+	// compute max impulse
+	this.jnMax = this.MaxForce * dt
+
+	// calculate bias velocity
+	this.bias = FClamp(-bias_coef(this.ErrorBias, dt)*(dist-this.Dist)/dt, -this.MaxBias, this.MaxBias)
+}
+
+func (this *PinJoint) ApplyCachedImpulse(dt_coef float32) {
+	j := Mult(this.n, this.jnAcc*dt_coef)
+	apply_impulses(this.BodyA, this.BodyB, this.r1, this.r2, j)
+}
+
+func (this *PinJoint) ApplyImpulse() {
+	a, b := this.BodyA, this.BodyB
+	n := this.n
+
+	// compute relative velocity
+	vrn := normal_relative_velocity(a, b, this.r1, this.r2, n)
+
+	// compute normal impulse
+	jn := (this.bias - vrn) * this.nMass
+	jnOld := this.jnAcc
+	this.jnAcc = FClamp(jnOld+jn, -this.jnMax, this.jnMax)
+	jn = this.jnAcc - jnOld
+
+	// apply impulse
+	apply_impulses(a, b, this.r1, this.r2, Mult(n, jn))
+}
+
+func (this *PinJoint) Impulse() float32 {
+	return FAbs(this.jnAcc)
+}
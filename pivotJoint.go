@@ -27,8 +27,8 @@ func NewPivotJoint(a, b *Body) *PivotJoint {
 func (this *PivotJoint) PreStep(dt float32) {
 	a, b := this.BodyA, this.BodyB
 
-	this.r1 = RotateVect(this.Anchor1, Rotation{a.rot.X, a.rot.Y})
-	this.r2 = RotateVect(this.Anchor2, Rotation{b.rot.X, b.rot.Y})
+	this.r1 = RotateVect(Sub(this.Anchor1, a.cog), Rotation{a.rot.X, a.rot.Y})
+	this.r2 = RotateVect(Sub(this.Anchor2, b.cog), Rotation{b.rot.X, b.rot.Y})
 
 	// Calculate mass tensor
 	k_tensor(a, b, this.r1, this.r2, &this.k1, &this.k2)
@@ -44,27 +44,28 @@ func NewPolygon(verts Vertices, offset Vect) *Shape {
 	return shape
 }
 
-func (poly *PolygonShape) Moment(mass float32) float32 {
-
-	sum1 := float32(0)
-	sum2 := float32(0)
-
-	println("using bad Moment calculation")
-	offset := Vect{0, 0}
-
-	for i := 0; i < poly.NumVerts; i++ {
-
-		v1 := Add(poly.Verts[i], offset)
-		v2 := Add(poly.Verts[(i+1)%poly.NumVerts], offset)
+// Splits the outline into convex polygons with Vertices.ConvexDecomposition() and adds them to the body.
+// The outline can be concave and wound either way. Returns the new shapes.
+func NewPolygonsFromOutline(body *Body, verts Vertices) []*Shape {
+	var shapes []*Shape
+	for _, piece := range verts.ConvexDecomposition() {
+		shape := NewPolygon(piece, Vector_Zero)
+		body.AddShape(shape)
+		shapes = append(shapes, shape)
+	}
+	return shapes
+}
 
-		a := Cross(v2, v1)
-		b := Dot(v1, v1) + Dot(v1, v2) + Dot(v2, v2)
+func (poly *PolygonShape) Moment(mass float32) float32 {
+	return MomentForPoly(mass, poly.Verts, Vector_Zero)
+}
 
-		sum1 += a * b
-		sum2 += a
-	}
+func (poly *PolygonShape) area() float32 {
+	return AreaForPoly(poly.Verts)
+}
 
-	return (float32(mass) * sum1) / (6.0 * sum2)
+func (poly *PolygonShape) centroid() Vect {
+	return CentroidForPoly(poly.Verts)
 }
 
 // Sets the vertices offset by the offset and calculates the PolygonAxes.
@@ -120,10 +121,10 @@ func (poly *PolygonShape) Clone(s *Shape) ShapeClass {
 
 func (poly *PolygonShape) Clone2(s *Shape) *PolygonShape {
 	clone := *poly
-	clone.Verts = make(Vertices, len(poly.Verts))
-	clone.TVerts = make(Vertices, len(poly.TVerts))
-	clone.Axes = make([]PolygonAxis, len(poly.Axes))
-	clone.TAxes = make([]PolygonAxis, len(poly.TAxes))
+	clone.Verts = make(Vertices, 0, len(poly.Verts))
+	clone.TVerts = make(Vertices, 0, len(poly.TVerts))
+	clone.Axes = make([]PolygonAxis, 0, len(poly.Axes))
+	clone.TAxes = make([]PolygonAxis, 0, len(poly.TAxes))
 
 	clone.Verts = append(clone.Verts, poly.Verts...)
 	clone.TVerts = append(clone.TVerts, poly.TVerts...)
@@ -187,6 +188,52 @@ func (poly *PolygonShape) update(xf Transform) AABB {
 	}
 }
 
+func (poly *PolygonShape) segmentQuery(a, b Vect, r float32, info *SegmentQueryInfo) bool {
+	axes := poly.TAxes
+	verts := poly.TVerts
+	numVerts := poly.NumVerts
+
+	hit := false
+	for i := 0; i < numVerts; i++ {
+		n := axes[i].N
+		d := axes[i].D + r
+		an := Dot(a, n)
+		if d > an {
+			continue
+		}
+
+		bn := Dot(b, n)
+		t := (d - an) / (bn - an)
+		if t < 0 || 1 < t || (hit && t >= info.Alpha) {
+			continue
+		}
+
+		point := Lerp(a, b, t)
+		dt := -Cross(n, point)
+		dtMin := -Cross(n, verts[i])
+		dtMax := -Cross(n, verts[(i+1)%numVerts])
+
+		if dtMin <= dt && dt <= dtMax {
+			info.set(poly.Shape, a, b, n, t)
+			info.Point = Sub(info.Point, Mult(n, r))
+			hit = true
+		}
+	}
+
+	// The swept circle can also hit the rounded corners.
+	if r > 0 {
+		for i := 0; i < numVerts; i++ {
+			var vertInfo SegmentQueryInfo
+			if circleSegmentQuery(poly.Shape, verts[i], 0, a, b, r, &vertInfo) && (!hit || vertInfo.Alpha < info.Alpha) {
+				*info = vertInfo
+				hit = true
+			}
+		}
+	}
+
+	return hit
+}
+
 // Returns true if the given point is located inside the box.
 func (poly *PolygonShape) TestPoint(point Vect) bool {
 	return poly.ContainsVert(point)
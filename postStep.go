@@ -0,0 +1,50 @@
+package chipmunk
+
+import (
+	"reflect"
+)
+
+// Function called by the space after a step.
+type PostStepFunc func(space *Space)
+
+type postStepCallback struct {
+	key interface{}
+	fnc PostStepFunc
+}
+
+// Schedules fnc to be called once the space finishes the current step or query.
+// This is the place to add or remove bodies, shapes and constraints from collision callbacks.
+// Only the first callback registered for a key is kept until it runs, so the same object
+// can be scheduled for removal from several callbacks, usually the key is a pointer to the object.
+// Returns false if a callback with the same key is already scheduled, or without scheduling fnc
+// if key can't be compared, like slices and maps.
+// If the space isn't locked fnc is called immediately.
+func (space *Space) AddPostStepCallback(key interface{}, fnc PostStepFunc) bool {
+	if key != nil && !reflect.TypeOf(key).Comparable() {
+		return false
+	}
+	if space.locked == 0 {
+		fnc(space)
+		return true
+	}
+
+	for _, callback := range space.postStepCallbacks {
+		if callback.key == key {
+			return false
+		}
+	}
+
+	space.postStepCallbacks = append(space.postStepCallbacks, postStepCallback{key, fnc})
+	return true
+}
+
+func (space *Space) runPostStepCallbacks() {
+	// Callbacks may schedule more callbacks, keep running until the queue is empty.
+	for len(space.postStepCallbacks) > 0 {
+		callbacks := space.postStepCallbacks
+		space.postStepCallbacks = nil
+		for _, callback := range callbacks {
+			callback.fnc(space)
+		}
+	}
+}
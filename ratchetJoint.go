@@ -0,0 +1,91 @@
+package chipmunk
+
+import (
+	"math"
+)
+
+// Works like a socket wrench, the relative angle of the bodies can only advance in steps of Ratchet.
+type RatchetJoint struct {
+	BasicConstraint
+	Angle, Phase, Ratchet float32
+
+	iSum float32
+	bias float32
+
+	jAcc, jMax float32
+}
+
+func NewRatchetJoint(a, b *Body, phase, ratchet float32) *RatchetJoint {
+	return &RatchetJoint{
+		BasicConstraint: NewConstraint(a, b),
+		Angle:           b.a - a.a,
+		Phase:           phase,
+		Ratchet:         ratchet,
+	}
+}
+
+func (this *RatchetJoint) PreStep(dt float32) {
+	a, b := this.BodyA, this.BodyB
+
+	angle := this.Angle
+	phase := this.Phase
+	ratchet := this.Ratchet
+
+	delta := b.a - a.a
+	diff := angle - delta
+	pdist := float32(0)
+
+	if diff*ratchet > 0 {
+		pdist = diff
+	} else {
+		this.Angle = float32(math.Floor(float64((delta-phase)/ratchet)))*ratchet + phase
+	}
+
+	// calculate moment of inertia coefficient.
+	this.iSum = 1 / (a.i_inv + b.i_inv)
+
+	// compute max impulse
+	this.jMax = this.MaxForce * dt
+
+	// calculate bias velocity
+	this.bias = FClamp(-bias_coef(this.ErrorBias, dt)*pdist/dt, -this.MaxBias, this.MaxBias)
+
+	// If the bias is 0, the joint is not at a limit. Reset the impulse.
+	if this.bias == 0 {
+		this.jAcc = 0
+	}
+}
+
+func (this *RatchetJoint) ApplyCachedImpulse(dt_coef float32) {
+	a, b := this.BodyA, this.BodyB
+
+	j := this.jAcc * dt_coef
+	a.w -= j * a.i_inv
+	b.w += j * b.i_inv
+}
+
+func (this *RatchetJoint) ApplyImpulse() {
+	if this.bias == 0 {
+		return // early exit
+	}
+
+	a, b := this.BodyA, this.BodyB
+
+	// compute relative rotational velocity
+	wr := b.w - a.w
+	ratchet := this.Ratchet
+
+	// compute normal impulse
+	j := -(this.bias + wr) * this.iSum
+	jOld := this.jAcc
+	this.jAcc = FClamp((jOld+j)*ratchet, 0, this.jMax*FAbs(ratchet)) / ratchet
+	j = this.jAcc - jOld
+
+	// apply impulse
+	a.w -= j * a.i_inv
+	b.w += j * b.i_inv
+}
+
+func (this *RatchetJoint) Impulse() float32 {
+	return FAbs(this.jAcc)
+}
@@ -0,0 +1,83 @@
+package chipmunk
+
+// Keeps the relative angle of two bodies between Min and Max.
+type RotaryLimitJoint struct {
+	BasicConstraint
+	Min, Max float32
+
+	iSum float32
+	bias float32
+
+	jAcc, jMax float32
+}
+
+func NewRotaryLimitJoint(a, b *Body, min, max float32) *RotaryLimitJoint {
+	return &RotaryLimitJoint{
+		BasicConstraint: NewConstraint(a, b),
+		Min:             min,
+		Max:             max,
+	}
+}
+
+func (this *RotaryLimitJoint) PreStep(dt float32) {
+	a, b := this.BodyA, this.BodyB
+
+	dist := b.a - a.a
+	pdist := float32(0)
+	if dist > this.Max {
+		pdist = this.Max - dist
+	} else if dist < this.Min {
+		pdist = this.Min - dist
+	}
+
+	// calculate moment of inertia coefficient.
+	this.iSum = 1 / (a.i_inv + b.i_inv)
+
+	// compute max impulse
+	this.jMax = this.MaxForce * dt
+
+	// calculate bias velocity
+	this.bias = FClamp(-bias_coef(this.ErrorBias, dt)*pdist/dt, -this.MaxBias, this.MaxBias)
+
+	// If the bias is 0, the joint is not at a limit. Reset the impulse.
+	if this.bias == 0 {
+		this.jAcc = 0
+	}
+}
+
+func (this *RotaryLimitJoint) ApplyCachedImpulse(dt_coef float32) {
+	a, b := this.BodyA, this.BodyB
+
+	j := this.jAcc * dt_coef
+	a.w -= j * a.i_inv
+	b.w += j * b.i_inv
+}
+
+func (this *RotaryLimitJoint) ApplyImpulse() {
+	if this.bias == 0 {
+		return // early exit
+	}
+
+	a, b := this.BodyA, this.BodyB
+
+	// compute relative rotational velocity
+	wr := b.w - a.w
+
+	// compute normal impulse
+	j := -(this.bias + wr) * this.iSum
+	jOld := this.jAcc
+	if this.bias < 0 {
+		this.jAcc = FClamp(jOld+j, 0, this.jMax)
+	} else {
+		this.jAcc = FClamp(jOld+j, -this.jMax, 0)
+	}
+	j = this.jAcc - jOld
+
+	// apply impulse
+	a.w -= j * a.i_inv
+	b.w += j * b.i_inv
+}
+
+func (this *RotaryLimitJoint) Impulse() float32 {
+	return FAbs(this.jAcc)
+}
@@ -38,10 +38,15 @@ func (segment *SegmentShape) ShapeType() ShapeType {
 }
 
 func (segment *SegmentShape) Moment(mass float32) float32 {
+	return MomentForSegment(mass, segment.A, segment.B, segment.Radius)
+}
 
-	offset := Mult(Add(segment.A, segment.B), 0.5)
+func (segment *SegmentShape) area() float32 {
+	return AreaForSegment(segment.A, segment.B, segment.Radius)
+}
 
-	return float32(mass) * (DistSqr(segment.B, segment.A)/12.0 + LengthSqr(offset))
+func (segment *SegmentShape) centroid() Vect {
+	return Lerp(segment.A, segment.B, 0.5)
 }
 
 //Called to update N, Tn, Ta, Tb and the the bounding box.
@@ -73,6 +78,52 @@ func (segment *SegmentShape) Clone(s *Shape) ShapeClass {
 	return &clone
 }
 
+func (segment *SegmentShape) segmentQuery(a, b Vect, r2 float32, info *SegmentQueryInfo) bool {
+	n := segment.Tn
+	d := Dot(Sub(segment.Ta, a), n)
+	r := segment.Radius + r2
+
+	flippedN := n
+	if d > 0 {
+		flippedN = Mult(n, -1)
+	}
+	segOffset := Sub(Mult(flippedN, r), a)
+
+	// Make the endpoints relative to 'a' and move them by the thickness of the segment.
+	segA := Add(segment.Ta, segOffset)
+	segB := Add(segment.Tb, segOffset)
+	delta := Sub(b, a)
+
+	if Cross(delta, segA)*Cross(delta, segB) <= 0 {
+		dOffset := d + r
+		if d > 0 {
+			dOffset = d - r
+		}
+		ad := -dOffset
+		bd := Dot(delta, n) - dOffset
+
+		if ad*bd < 0 {
+			info.set(segment.Shape, a, b, flippedN, ad/(ad-bd))
+			info.Point = Sub(info.Point, Mult(flippedN, r2))
+			return true
+		}
+	} else if r != 0 {
+		var info1, info2 SegmentQueryInfo
+		hit1 := circleSegmentQuery(segment.Shape, segment.Ta, segment.Radius, a, b, r2, &info1)
+		hit2 := circleSegmentQuery(segment.Shape, segment.Tb, segment.Radius, a, b, r2, &info2)
+
+		if hit1 && (!hit2 || info1.Alpha < info2.Alpha) {
+			*info = info1
+			return true
+		} else if hit2 {
+			*info = info2
+			return true
+		}
+	}
+
+	return false
+}
+
 // Only returns false for now.
 func (segment *SegmentShape) TestPoint(point Vect) bool {
 	return false
@@ -0,0 +1,592 @@
+package chipmunk
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+)
+
+// Version of the JSON and binary formats written by this package.
+const SerializationVersion = 1
+
+// Type names of ShapeDef.Type and ConstraintDef.Type.
+const (
+	ShapeDefCircle  = "circle"
+	ShapeDefSegment = "segment"
+	ShapeDefPolygon = "polygon"
+	ShapeDefBox     = "box"
+
+	ConstraintDefPivot              = "pivot"
+	ConstraintDefDampedSpring       = "dampedSpring"
+	ConstraintDefGroove             = "groove"
+	ConstraintDefSlide              = "slide"
+	ConstraintDefPin                = "pin"
+	ConstraintDefRotaryLimit        = "rotaryLimit"
+	ConstraintDefRatchet            = "ratchet"
+	ConstraintDefGear               = "gear"
+	ConstraintDefSimpleMotor        = "simpleMotor"
+	ConstraintDefDampedRotarySpring = "dampedRotarySpring"
+)
+
+// A float32 that can be infinite, encoded as "inf" or "-inf" in JSON.
+type InfFloat float32
+
+func (f InfFloat) MarshalJSON() ([]byte, error) {
+	if math.IsInf(float64(f), 1) {
+		return []byte(`"inf"`), nil
+	}
+	if math.IsInf(float64(f), -1) {
+		return []byte(`"-inf"`), nil
+	}
+	return json.Marshal(float32(f))
+}
+
+func (f *InfFloat) UnmarshalJSON(data []byte) error {
+	switch string(data) {
+	case `"inf"`:
+		*f = InfFloat(math.Inf(1))
+		return nil
+	case `"-inf"`:
+		*f = InfFloat(math.Inf(-1))
+		return nil
+	}
+
+	var v float32
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*f = InfFloat(v)
+	return nil
+}
+
+// Serializable description of a space, its bodies and its constraints.
+type SpaceDef struct {
+	Version              int      `json:"version"`
+	Iterations           int      `json:"iterations"`
+	Gravity              Vect     `json:"gravity"`
+	LinearDamping        float32  `json:"linearDamping"`
+	AngularDamping       float32  `json:"angularDamping"`
+	IdleSpeedThreshold   float32  `json:"idleSpeedThreshold"`
+	SleepTimeThreshold   InfFloat `json:"sleepTimeThreshold"`
+	CollisionSlop        float32  `json:"collisionSlop"`
+	CollisionBias        float32  `json:"collisionBias"`
+	CollisionPersistence int64    `json:"collisionPersistence"`
+	EnableContactGraph   bool     `json:"enableContactGraph"`
+
+	Bodies      []BodyDef       `json:"bodies"`
+	Constraints []ConstraintDef `json:"constraints"`
+}
+
+// Serializable description of a body and its shapes.
+type BodyDef struct {
+	ID              uint32     `json:"id"`
+	Static          bool       `json:"static,omitempty"`
+	Kinematic       bool       `json:"kinematic,omitempty"`
+	Mass            InfFloat   `json:"mass"`
+	Moment          InfFloat   `json:"moment"`
+	Position        Vect       `json:"position"`
+	CenterOfGravity Vect       `json:"centerOfGravity"`
+	Velocity        Vect       `json:"velocity"`
+	Angle           float32    `json:"angle"`
+	AngularVelocity float32    `json:"angularVelocity"`
+	Shapes          []ShapeDef `json:"shapes"`
+}
+
+// Serializable description of a shape. Which geometry fields are used depends on Type.
+type ShapeDef struct {
+	Type string `json:"type"`
+
+	// Center of circles and boxes.
+	Position Vect `json:"position"`
+	// Radius of circles and segments.
+	Radius float32 `json:"radius,omitempty"`
+	// End points of segments.
+	A Vect `json:"a"`
+	B Vect `json:"b"`
+	// Vertices of polygons. They must be convex and may be wound either way.
+	Verts Vertices `json:"verts,omitempty"`
+	// Size of boxes.
+	Width  float32 `json:"width,omitempty"`
+	Height float32 `json:"height,omitempty"`
+
+	Friction        float32       `json:"friction"`
+	Elasticity      float32       `json:"elasticity"`
+	SurfaceVelocity Vect          `json:"surfaceVelocity"`
+	Group           Group         `json:"group"`
+	Layer           Layer         `json:"layer"`
+	Filter          ShapeFilter   `json:"filter"`
+	IsSensor        bool          `json:"sensor,omitempty"`
+	CollisionType   CollisionType `json:"collisionType"`
+	// Mass or density the body's mass is computed from.
+	Mass    float32 `json:"mass,omitempty"`
+	Density float32 `json:"density,omitempty"`
+}
+
+// Serializable description of a constraint. The bodies are referenced by their IDs.
+// Which joint fields are used depends on Type. Custom spring force and torque functions are not saved.
+type ConstraintDef struct {
+	Type         string   `json:"type"`
+	BodyA        uint32   `json:"bodyA"`
+	BodyB        uint32   `json:"bodyB"`
+	MaxForce     InfFloat `json:"maxForce"`
+	MaxBias      InfFloat `json:"maxBias"`
+	ErrorBias    float32  `json:"errorBias"`
+	BreakForce   InfFloat `json:"breakForce"`
+	BreakImpulse InfFloat `json:"breakImpulse"`
+
+	// Anchors of pivot, pin, slide and groove joints and of damped springs, groove joints only use Anchor2.
+	Anchor1 Vect `json:"anchor1"`
+	Anchor2 Vect `json:"anchor2"`
+	// Groove of groove joints.
+	GrooveA Vect `json:"grooveA"`
+	GrooveB Vect `json:"grooveB"`
+	// Damped spring and damped rotary spring parameters.
+	RestLength float32 `json:"restLength,omitempty"`
+	RestAngle  float32 `json:"restAngle,omitempty"`
+	Stiffness  float32 `json:"stiffness,omitempty"`
+	Damping    float32 `json:"damping,omitempty"`
+	// Distance of pin joints.
+	Dist float32 `json:"dist,omitempty"`
+	// Limits of slide and rotary limit joints.
+	Min float32 `json:"min,omitempty"`
+	Max float32 `json:"max,omitempty"`
+	// Ratchet and gear joint parameters.
+	Angle   float32 `json:"angle,omitempty"`
+	Phase   float32 `json:"phase,omitempty"`
+	Ratchet float32 `json:"ratchet,omitempty"`
+	Ratio   float32 `json:"ratio,omitempty"`
+	// Rate of simple motors.
+	Rate float32 `json:"rate,omitempty"`
+}
+
+// Fields missing from the JSON keep the defaults of NewSpace().
+func (def *SpaceDef) UnmarshalJSON(data []byte) error {
+	type plain SpaceDef
+	p := plain{
+		Iterations:           20,
+		LinearDamping:        1,
+		AngularDamping:       1,
+		SleepTimeThreshold:   InfFloat(Inf),
+		CollisionSlop:        0.5,
+		CollisionBias:        float32(math.Pow(1.0-0.1, 60)),
+		CollisionPersistence: 3,
+	}
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	*def = SpaceDef(p)
+	return nil
+}
+
+// Fields missing from the JSON keep the defaults of new shapes.
+func (def *ShapeDef) UnmarshalJSON(data []byte) error {
+	type plain ShapeDef
+	shape := newShape()
+	p := plain{Friction: shape.u, Elasticity: shape.e, Layer: shape.Layer, Filter: shape.Filter}
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	*def = ShapeDef(p)
+	return nil
+}
+
+// Fields missing from the JSON keep the defaults of NewConstraint().
+func (def *ConstraintDef) UnmarshalJSON(data []byte) error {
+	type plain ConstraintDef
+	con := NewConstraint(nil, nil)
+	p := plain{
+		MaxForce:     InfFloat(con.MaxForce),
+		MaxBias:      InfFloat(con.MaxBias),
+		ErrorBias:    con.ErrorBias,
+		BreakForce:   InfFloat(con.BreakForce),
+		BreakImpulse: InfFloat(con.BreakImpulse),
+	}
+	if err := json.Unmarshal(data, &p); err != nil {
+		return err
+	}
+	*def = ConstraintDef(p)
+	return nil
+}
+
+// Encodes the space as JSON. Bodies without an ID are given one in the data, the bodies are not changed.
+// Decode it with UnmarshalSpaceJSON().
+func (space *Space) MarshalJSON() ([]byte, error) {
+	def, err := space.Def()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(def)
+}
+
+// Creates a space from JSON written by Space.MarshalJSON().
+// Returns the space and its bodies by ID.
+func UnmarshalSpaceJSON(data []byte) (*Space, map[uint32]*Body, error) {
+	var def SpaceDef
+	if err := json.Unmarshal(data, &def); err != nil {
+		return nil, nil, err
+	}
+	return def.NewSpace()
+}
+
+// Returns the description of the space and of the bodies and constraints in it.
+// Bodies without an ID are given one in the description, the bodies are not changed.
+func (space *Space) Def() (*SpaceDef, error) {
+	if space.locked != 0 {
+		return nil, ErrSpaceLocked
+	}
+
+	def := &SpaceDef{
+		Version:              SerializationVersion,
+		Iterations:           space.Iterations,
+		Gravity:              space.Gravity,
+		LinearDamping:        space.LinearDamping,
+		AngularDamping:       space.AngularDamping,
+		IdleSpeedThreshold:   space.idleSpeedThreshold,
+		SleepTimeThreshold:   InfFloat(space.sleepTimeThreshold),
+		CollisionSlop:        space.collisionSlop,
+		CollisionBias:        space.collisionBias,
+		CollisionPersistence: space.collisionPersistence,
+		EnableContactGraph:   space.enableContactGraph,
+	}
+
+	contents, _, constraints, _ := space.contents()
+
+	// Bodies removed during the last step are still in the contents until the next step.
+	var bodies []*Body
+	ids := make(map[*Body]uint32)
+	used := make(map[uint32]bool)
+	maxID := uint32(0)
+	for _, body := range contents {
+		if body.deleted {
+			continue
+		}
+		if body.ID != 0 {
+			if used[body.ID] {
+				return nil, ErrDuplicateID
+			}
+			used[body.ID] = true
+			if body.ID > maxID {
+				maxID = body.ID
+			}
+		}
+		ids[body] = body.ID
+		bodies = append(bodies, body)
+	}
+	for _, body := range bodies {
+		if ids[body] == 0 {
+			maxID++
+			ids[body] = maxID
+		}
+	}
+	sort.Slice(bodies, func(i, j int) bool {
+		return ids[bodies[i]] < ids[bodies[j]]
+	})
+
+	for _, body := range bodies {
+		bodyDef, err := body.def(ids[body])
+		if err != nil {
+			return nil, err
+		}
+		def.Bodies = append(def.Bodies, bodyDef)
+	}
+
+	for _, constraint := range constraints {
+		con := constraint.Constraint()
+		if con.BodyA.deleted || con.BodyB.deleted {
+			continue
+		}
+
+		conDef := ConstraintDef{
+			BodyA:        ids[con.BodyA],
+			BodyB:        ids[con.BodyB],
+			MaxForce:     InfFloat(con.MaxForce),
+			MaxBias:      InfFloat(con.MaxBias),
+			ErrorBias:    con.ErrorBias,
+			BreakForce:   InfFloat(con.BreakForce),
+			BreakImpulse: InfFloat(con.BreakImpulse),
+		}
+		switch c := constraint.(type) {
+		case *PivotJoint:
+			conDef.Type = ConstraintDefPivot
+			conDef.Anchor1, conDef.Anchor2 = c.Anchor1, c.Anchor2
+		case *DampedSpring:
+			conDef.Type = ConstraintDefDampedSpring
+			conDef.Anchor1, conDef.Anchor2 = c.Anchor1, c.Anchor2
+			conDef.RestLength, conDef.Stiffness, conDef.Damping = c.RestLength, c.Stiffness, c.Damping
+		case *GrooveJoint:
+			conDef.Type = ConstraintDefGroove
+			conDef.GrooveA, conDef.GrooveB, conDef.Anchor2 = c.GrooveA, c.GrooveB, c.Anchor2
+		case *SlideJoint:
+			conDef.Type = ConstraintDefSlide
+			conDef.Anchor1, conDef.Anchor2 = c.Anchor1, c.Anchor2
+			conDef.Min, conDef.Max = c.Min, c.Max
+		case *PinJoint:
+			conDef.Type = ConstraintDefPin
+			conDef.Anchor1, conDef.Anchor2 = c.Anchor1, c.Anchor2
+			conDef.Dist = c.Dist
+		case *RotaryLimitJoint:
+			conDef.Type = ConstraintDefRotaryLimit
+			conDef.Min, conDef.Max = c.Min, c.Max
+		case *RatchetJoint:
+			conDef.Type = ConstraintDefRatchet
+			conDef.Angle, conDef.Phase, conDef.Ratchet = c.Angle, c.Phase, c.Ratchet
+		case *GearJoint:
+			conDef.Type = ConstraintDefGear
+			conDef.Phase, conDef.Ratio = c.Phase, c.Ratio
+		case *SimpleMotor:
+			conDef.Type = ConstraintDefSimpleMotor
+			conDef.Rate = c.Rate
+		case *DampedRotarySpring:
+			conDef.Type = ConstraintDefDampedRotarySpring
+			conDef.RestAngle, conDef.Stiffness, conDef.Damping = c.RestAngle, c.Stiffness, c.Damping
+		default:
+			return nil, ErrUnsupportedType
+		}
+		def.Constraints = append(def.Constraints, conDef)
+	}
+
+	return def, nil
+}
+
+// Creates a space from the description. Returns the space and its bodies by ID.
+func (def *SpaceDef) NewSpace() (*Space, map[uint32]*Body, error) {
+	if def.Version < 1 || def.Version > SerializationVersion {
+		return nil, nil, ErrUnsupportedVersion
+	}
+
+	space := NewSpace()
+	space.Iterations = def.Iterations
+	space.Gravity = def.Gravity
+	space.LinearDamping = def.LinearDamping
+	space.AngularDamping = def.AngularDamping
+	space.idleSpeedThreshold = def.IdleSpeedThreshold
+	space.sleepTimeThreshold = float32(def.SleepTimeThreshold)
+	space.collisionSlop = def.CollisionSlop
+	space.collisionBias = def.CollisionBias
+	space.collisionPersistence = def.CollisionPersistence
+	space.enableContactGraph = def.EnableContactGraph
+
+	bodies := make(map[uint32]*Body, len(def.Bodies))
+	for i := range def.Bodies {
+		bodyDef := &def.Bodies[i]
+		if bodyDef.ID == 0 {
+			return nil, nil, ErrUnknownID
+		}
+		if bodies[bodyDef.ID] != nil {
+			return nil, nil, ErrDuplicateID
+		}
+
+		body, err := bodyDef.NewBody()
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := space.TryAddBody(body); err != nil {
+			return nil, nil, err
+		}
+		bodies[body.ID] = body
+	}
+
+	for i := range def.Constraints {
+		conDef := &def.Constraints[i]
+		a, b := bodies[conDef.BodyA], bodies[conDef.BodyB]
+		if a == nil || b == nil {
+			return nil, nil, ErrUnknownID
+		}
+
+		var constraint Constraint
+		switch conDef.Type {
+		case ConstraintDefPivot:
+			constraint = NewPivotJointAnchor(a, b, conDef.Anchor1, conDef.Anchor2)
+		case ConstraintDefDampedSpring:
+			constraint = NewDampedSpring(a, b, conDef.Anchor1, conDef.Anchor2, conDef.RestLength, conDef.Stiffness, conDef.Damping)
+		case ConstraintDefGroove:
+			constraint = NewGrooveJoint(a, b, conDef.GrooveA, conDef.GrooveB, conDef.Anchor2)
+		case ConstraintDefSlide:
+			constraint = NewSlideJoint(a, b, conDef.Anchor1, conDef.Anchor2, conDef.Min, conDef.Max)
+		case ConstraintDefPin:
+			pin := NewPinJoint(a, b, conDef.Anchor1, conDef.Anchor2)
+			pin.Dist = conDef.Dist
+			constraint = pin
+		case ConstraintDefRotaryLimit:
+			constraint = NewRotaryLimitJoint(a, b, conDef.Min, conDef.Max)
+		case ConstraintDefRatchet:
+			ratchet := NewRatchetJoint(a, b, conDef.Phase, conDef.Ratchet)
+			ratchet.Angle = conDef.Angle
+			constraint = ratchet
+		case ConstraintDefGear:
+			constraint = NewGearJoint(a, b, conDef.Phase, conDef.Ratio)
+		case ConstraintDefSimpleMotor:
+			constraint = NewSimpleMotor(a, b, conDef.Rate)
+		case ConstraintDefDampedRotarySpring:
+			constraint = NewDampedRotarySpring(a, b, conDef.RestAngle, conDef.Stiffness, conDef.Damping)
+		default:
+			return nil, nil, ErrUnsupportedType
+		}
+
+		con := constraint.Constraint()
+		con.MaxForce = float32(conDef.MaxForce)
+		con.MaxBias = float32(conDef.MaxBias)
+		con.ErrorBias = conDef.ErrorBias
+		con.BreakForce = float32(conDef.BreakForce)
+		con.BreakImpulse = float32(conDef.BreakImpulse)
+
+		if err := space.TryAddConstraint(constraint); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return space, bodies, nil
+}
+
+// Returns the description of the body and its shapes.
+func (body *Body) Def() (BodyDef, error) {
+	return body.def(body.ID)
+}
+
+func (body *Body) def(id uint32) (BodyDef, error) {
+	def := BodyDef{
+		ID:              id,
+		Static:          body.IsStatic(),
+		Kinematic:       body.IsKinematic(),
+		Mass:            InfFloat(body.m),
+		Moment:          InfFloat(body.i),
+		Position:        body.Position(),
+		CenterOfGravity: body.cog,
+		Velocity:        body.v,
+		Angle:           body.a,
+		AngularVelocity: body.w,
+	}
+
+	for _, shape := range body.Shapes {
+		// Shapes removed from the space stay in body.Shapes.
+		if shape.Body != body {
+			continue
+		}
+		shapeDef, err := shape.Def()
+		if err != nil {
+			return def, err
+		}
+		def.Shapes = append(def.Shapes, shapeDef)
+	}
+
+	return def, nil
+}
+
+// Creates a body with the shapes from the description.
+func (def *BodyDef) NewBody() (*Body, error) {
+	var body *Body
+	if def.Static {
+		body = NewBodyStatic()
+	} else if def.Kinematic {
+		body = NewBodyKinematic()
+		body.SetVelocity(def.Velocity.X, def.Velocity.Y)
+		body.SetAngularVelocity(def.AngularVelocity)
+	} else {
+		if !isPositive(float32(def.Mass)) {
+			return nil, ErrInvalidMass
+		}
+		if !isPositive(float32(def.Moment)) {
+			return nil, ErrInvalidMoment
+		}
+		body = NewBody(float32(def.Mass), float32(def.Moment))
+		body.SetVelocity(def.Velocity.X, def.Velocity.Y)
+		body.SetAngularVelocity(def.AngularVelocity)
+	}
+
+	body.ID = def.ID
+	body.cog = def.CenterOfGravity
+	body.SetPosition(def.Position)
+	body.SetAngle(def.Angle)
+
+	for i := range def.Shapes {
+		shape, err := def.Shapes[i].NewShape()
+		if err != nil {
+			return nil, err
+		}
+		body.AddShape(shape)
+	}
+	// The mass in the description is the one computed from the shapes.
+	body.massDirty = false
+
+	return body, nil
+}
+
+// Returns the description of the shape.
+func (shape *Shape) Def() (ShapeDef, error) {
+	def := ShapeDef{
+		Friction:        shape.u,
+		Elasticity:      shape.e,
+		SurfaceVelocity: shape.Surface_v,
+		Group:           shape.Group,
+		Layer:           shape.Layer,
+		Filter:          shape.Filter,
+		IsSensor:        shape.IsSensor,
+		CollisionType:   shape.CollisionType,
+		Mass:            shape.mass,
+		Density:         shape.density,
+	}
+
+	switch class := shape.ShapeClass.(type) {
+	case *CircleShape:
+		def.Type = ShapeDefCircle
+		def.Position = class.Position
+		def.Radius = class.Radius
+	case *SegmentShape:
+		def.Type = ShapeDefSegment
+		def.A, def.B = class.A, class.B
+		def.Radius = class.Radius
+	case *PolygonShape:
+		def.Type = ShapeDefPolygon
+		def.Verts = append(Vertices(nil), class.Verts...)
+	case *BoxShape:
+		def.Type = ShapeDefBox
+		def.Position = class.Position
+		def.Width, def.Height = class.Width, class.Height
+	default:
+		return def, ErrUnsupportedType
+	}
+
+	return def, nil
+}
+
+// Creates a shape from the description. The shape has no body.
+func (def *ShapeDef) NewShape() (*Shape, error) {
+	var shape *Shape
+	switch def.Type {
+	case ShapeDefCircle:
+		shape = NewCircle(def.Position, def.Radius)
+	case ShapeDefSegment:
+		shape = NewSegment(def.A, def.B, def.Radius)
+	case ShapeDefPolygon:
+		if len(def.Verts) < 3 {
+			return nil, ErrInvalidData
+		}
+		// Counter-clockwise vertices are reversed, concave and degenerate polygons are rejected.
+		verts := append(Vertices(nil), def.Verts...)
+		verts.ForceClockwise()
+		if !verts.IsClockwise() || !verts.ValidatePolygon() {
+			return nil, ErrInvalidData
+		}
+		shape = NewPolygon(verts, Vector_Zero)
+	case ShapeDefBox:
+		shape = NewBox(def.Position, def.Width, def.Height)
+	default:
+		return nil, ErrUnsupportedType
+	}
+	if !validShapeMass(def.Mass) || !validShapeMass(def.Density) {
+		return nil, ErrInvalidMass
+	}
+
+	shape.u = def.Friction
+	shape.e = def.Elasticity
+	shape.mass = def.Mass
+	shape.density = def.Density
+	shape.Surface_v = def.SurfaceVelocity
+	shape.Group = def.Group
+	shape.Layer = def.Layer
+	shape.Filter = def.Filter
+	shape.IsSensor = def.IsSensor
+	shape.CollisionType = def.CollisionType
+
+	return shape, nil
+}
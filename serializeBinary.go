@@ -0,0 +1,423 @@
+package chipmunk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// Magic bytes at the start of a space encoded by Space.MarshalBinary().
+const binaryMagic = "CPSP"
+
+// Type codes of the binary format, the index in the slices is written to the data.
+var (
+	binaryShapeTypes      = []string{ShapeDefCircle, ShapeDefSegment, ShapeDefPolygon, ShapeDefBox}
+	binaryConstraintTypes = []string{
+		ConstraintDefPivot, ConstraintDefDampedSpring, ConstraintDefGroove, ConstraintDefSlide, ConstraintDefPin,
+		ConstraintDefRotaryLimit, ConstraintDefRatchet, ConstraintDefGear, ConstraintDefSimpleMotor, ConstraintDefDampedRotarySpring,
+	}
+)
+
+// Encodes the space in a compact little-endian binary format that starts with a version number.
+// Bodies without an ID are given one in the data, the bodies are not changed. Decode it with UnmarshalSpaceBinary().
+func (space *Space) MarshalBinary() ([]byte, error) {
+	def, err := space.Def()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	w := &binaryWriter{w: &buf}
+
+	w.write([]byte(binaryMagic))
+	w.write(uint16(def.Version))
+
+	w.write(int32(def.Iterations))
+	w.write(def.Gravity)
+	w.write(def.LinearDamping)
+	w.write(def.AngularDamping)
+	w.write(def.IdleSpeedThreshold)
+	w.write(float32(def.SleepTimeThreshold))
+	w.write(def.CollisionSlop)
+	w.write(def.CollisionBias)
+	w.write(def.CollisionPersistence)
+	w.write(def.EnableContactGraph)
+
+	w.write(uint32(len(def.Bodies)))
+	for i := range def.Bodies {
+		body := &def.Bodies[i]
+		w.write(body.ID)
+		w.writeBodyType(body)
+		w.write(float32(body.Mass))
+		w.write(float32(body.Moment))
+		w.write(body.Position)
+		w.write(body.CenterOfGravity)
+		w.write(body.Velocity)
+		w.write(body.Angle)
+		w.write(body.AngularVelocity)
+
+		w.write(uint32(len(body.Shapes)))
+		for j := range body.Shapes {
+			w.writeShape(&body.Shapes[j])
+		}
+	}
+
+	w.write(uint32(len(def.Constraints)))
+	for i := range def.Constraints {
+		w.writeConstraint(&def.Constraints[i])
+	}
+
+	if w.err != nil {
+		return nil, w.err
+	}
+	return buf.Bytes(), nil
+}
+
+// Creates a space from data written by Space.MarshalBinary().
+// Returns the space and its bodies by ID.
+func UnmarshalSpaceBinary(data []byte) (*Space, map[uint32]*Body, error) {
+	r := &binaryReader{r: bytes.NewReader(data)}
+
+	magic := make([]byte, len(binaryMagic))
+	r.read(magic)
+	if r.err != nil || string(magic) != binaryMagic {
+		return nil, nil, ErrInvalidData
+	}
+
+	var version uint16
+	r.read(&version)
+	if version < 1 || version > SerializationVersion {
+		return nil, nil, ErrUnsupportedVersion
+	}
+
+	def := &SpaceDef{Version: int(version)}
+
+	var iterations int32
+	var sleepTimeThreshold float32
+	r.read(&iterations)
+	r.read(&def.Gravity)
+	r.read(&def.LinearDamping)
+	r.read(&def.AngularDamping)
+	r.read(&def.IdleSpeedThreshold)
+	r.read(&sleepTimeThreshold)
+	r.read(&def.CollisionSlop)
+	r.read(&def.CollisionBias)
+	r.read(&def.CollisionPersistence)
+	r.read(&def.EnableContactGraph)
+	def.Iterations = int(iterations)
+	def.SleepTimeThreshold = InfFloat(sleepTimeThreshold)
+
+	numBodies := r.readCount()
+	for i := 0; i < numBodies && r.err == nil; i++ {
+		var body BodyDef
+		var mass, moment float32
+		r.read(&body.ID)
+		body.Static, body.Kinematic = r.readBodyType()
+		r.read(&mass)
+		r.read(&moment)
+		r.read(&body.Position)
+		r.read(&body.CenterOfGravity)
+		r.read(&body.Velocity)
+		r.read(&body.Angle)
+		r.read(&body.AngularVelocity)
+		body.Mass, body.Moment = InfFloat(mass), InfFloat(moment)
+
+		numShapes := r.readCount()
+		for j := 0; j < numShapes && r.err == nil; j++ {
+			body.Shapes = append(body.Shapes, r.readShape())
+		}
+		def.Bodies = append(def.Bodies, body)
+	}
+
+	numConstraints := r.readCount()
+	for i := 0; i < numConstraints && r.err == nil; i++ {
+		def.Constraints = append(def.Constraints, r.readConstraint())
+	}
+
+	if r.err != nil {
+		return nil, nil, r.err
+	}
+	return def.NewSpace()
+}
+
+// Returns the index of name in types, or -1.
+func typeCode(types []string, name string) int {
+	for i, t := range types {
+		if t == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Writes little-endian values and keeps the first error.
+type binaryWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (w *binaryWriter) write(v interface{}) {
+	if w.err == nil {
+		w.err = binary.Write(w.w, binary.LittleEndian, v)
+	}
+}
+
+// Body types are written as a byte, 0 for dynamic bodies, 1 for static bodies and 2 for kinematic bodies.
+func (w *binaryWriter) writeBodyType(body *BodyDef) {
+	var code uint8
+	if body.Static {
+		code = 1
+	} else if body.Kinematic {
+		code = 2
+	}
+	w.write(code)
+}
+
+func (w *binaryWriter) writeShape(shape *ShapeDef) {
+	code := typeCode(binaryShapeTypes, shape.Type)
+	if code < 0 {
+		if w.err == nil {
+			w.err = ErrUnsupportedType
+		}
+		return
+	}
+
+	w.write(uint8(code))
+	w.write(shape.Friction)
+	w.write(shape.Elasticity)
+	w.write(shape.SurfaceVelocity)
+	w.write(int32(shape.Group))
+	w.write(int32(shape.Layer))
+	w.write(int32(shape.Filter.Group))
+	w.write(int32(shape.Filter.Categories))
+	w.write(int32(shape.Filter.Mask))
+	w.write(shape.IsSensor)
+	w.write(uint32(shape.CollisionType))
+	w.write(shape.Mass)
+	w.write(shape.Density)
+
+	switch shape.Type {
+	case ShapeDefCircle:
+		w.write(shape.Position)
+		w.write(shape.Radius)
+	case ShapeDefSegment:
+		w.write(shape.A)
+		w.write(shape.B)
+		w.write(shape.Radius)
+	case ShapeDefPolygon:
+		w.write(uint32(len(shape.Verts)))
+		w.write([]Vect(shape.Verts))
+	case ShapeDefBox:
+		w.write(shape.Position)
+		w.write(shape.Width)
+		w.write(shape.Height)
+	}
+}
+
+func (w *binaryWriter) writeConstraint(con *ConstraintDef) {
+	code := typeCode(binaryConstraintTypes, con.Type)
+	if code < 0 {
+		if w.err == nil {
+			w.err = ErrUnsupportedType
+		}
+		return
+	}
+
+	w.write(uint8(code))
+	w.write(con.BodyA)
+	w.write(con.BodyB)
+	w.write(float32(con.MaxForce))
+	w.write(float32(con.MaxBias))
+	w.write(con.ErrorBias)
+	w.write(float32(con.BreakForce))
+	w.write(float32(con.BreakImpulse))
+
+	switch con.Type {
+	case ConstraintDefPivot:
+		w.write(con.Anchor1)
+		w.write(con.Anchor2)
+	case ConstraintDefDampedSpring:
+		w.write(con.Anchor1)
+		w.write(con.Anchor2)
+		w.write(con.RestLength)
+		w.write(con.Stiffness)
+		w.write(con.Damping)
+	case ConstraintDefGroove:
+		w.write(con.GrooveA)
+		w.write(con.GrooveB)
+		w.write(con.Anchor2)
+	case ConstraintDefSlide:
+		w.write(con.Anchor1)
+		w.write(con.Anchor2)
+		w.write(con.Min)
+		w.write(con.Max)
+	case ConstraintDefPin:
+		w.write(con.Anchor1)
+		w.write(con.Anchor2)
+		w.write(con.Dist)
+	case ConstraintDefRotaryLimit:
+		w.write(con.Min)
+		w.write(con.Max)
+	case ConstraintDefRatchet:
+		w.write(con.Angle)
+		w.write(con.Phase)
+		w.write(con.Ratchet)
+	case ConstraintDefGear:
+		w.write(con.Phase)
+		w.write(con.Ratio)
+	case ConstraintDefSimpleMotor:
+		w.write(con.Rate)
+	case ConstraintDefDampedRotarySpring:
+		w.write(con.RestAngle)
+		w.write(con.Stiffness)
+		w.write(con.Damping)
+	}
+}
+
+// Reads little-endian values and keeps the first error.
+type binaryReader struct {
+	r   *bytes.Reader
+	err error
+}
+
+func (r *binaryReader) read(v interface{}) {
+	if r.err != nil {
+		return
+	}
+	if err := binary.Read(r.r, binary.LittleEndian, v); err != nil {
+		r.err = ErrInvalidData
+	}
+}
+
+// Reads the length of a list. Fails if the remaining data can't hold that many elements.
+func (r *binaryReader) readCount() int {
+	var count uint32
+	r.read(&count)
+	if r.err == nil && int64(count) > int64(r.r.Len()) {
+		r.err = ErrInvalidData
+	}
+	return int(count)
+}
+
+func (r *binaryReader) readType(types []string) string {
+	var code uint8
+	r.read(&code)
+	if r.err != nil {
+		return ""
+	}
+	if int(code) >= len(types) {
+		r.err = ErrUnsupportedType
+		return ""
+	}
+	return types[code]
+}
+
+// Returns the static and kinematic flags of a body type written by writeBodyType().
+func (r *binaryReader) readBodyType() (static, kinematic bool) {
+	var code uint8
+	r.read(&code)
+	if r.err == nil && code > 2 {
+		r.err = ErrInvalidData
+	}
+	return code == 1, code == 2
+}
+
+func (r *binaryReader) readShape() ShapeDef {
+	var shape ShapeDef
+	var group, layer, filterGroup, categories, mask int32
+	var collisionType uint32
+	shape.Type = r.readType(binaryShapeTypes)
+	r.read(&shape.Friction)
+	r.read(&shape.Elasticity)
+	r.read(&shape.SurfaceVelocity)
+	r.read(&group)
+	r.read(&layer)
+	r.read(&filterGroup)
+	r.read(&categories)
+	r.read(&mask)
+	r.read(&shape.IsSensor)
+	r.read(&collisionType)
+	shape.Group, shape.Layer = Group(group), Layer(layer)
+	shape.Filter = ShapeFilter{Group: Group(filterGroup), Categories: Layer(categories), Mask: Layer(mask)}
+	shape.CollisionType = CollisionType(collisionType)
+	r.read(&shape.Mass)
+	r.read(&shape.Density)
+
+	switch shape.Type {
+	case ShapeDefCircle:
+		r.read(&shape.Position)
+		r.read(&shape.Radius)
+	case ShapeDefSegment:
+		r.read(&shape.A)
+		r.read(&shape.B)
+		r.read(&shape.Radius)
+	case ShapeDefPolygon:
+		numVerts := r.readCount()
+		if r.err == nil {
+			shape.Verts = make(Vertices, numVerts)
+			r.read([]Vect(shape.Verts))
+		}
+	case ShapeDefBox:
+		r.read(&shape.Position)
+		r.read(&shape.Width)
+		r.read(&shape.Height)
+	}
+	return shape
+}
+
+func (r *binaryReader) readConstraint() ConstraintDef {
+	var con ConstraintDef
+	var maxForce, maxBias, breakForce, breakImpulse float32
+	con.Type = r.readType(binaryConstraintTypes)
+	r.read(&con.BodyA)
+	r.read(&con.BodyB)
+	r.read(&maxForce)
+	r.read(&maxBias)
+	r.read(&con.ErrorBias)
+	r.read(&breakForce)
+	r.read(&breakImpulse)
+	con.MaxForce, con.MaxBias = InfFloat(maxForce), InfFloat(maxBias)
+	con.BreakForce, con.BreakImpulse = InfFloat(breakForce), InfFloat(breakImpulse)
+
+	switch con.Type {
+	case ConstraintDefPivot:
+		r.read(&con.Anchor1)
+		r.read(&con.Anchor2)
+	case ConstraintDefDampedSpring:
+		r.read(&con.Anchor1)
+		r.read(&con.Anchor2)
+		r.read(&con.RestLength)
+		r.read(&con.Stiffness)
+		r.read(&con.Damping)
+	case ConstraintDefGroove:
+		r.read(&con.GrooveA)
+		r.read(&con.GrooveB)
+		r.read(&con.Anchor2)
+	case ConstraintDefSlide:
+		r.read(&con.Anchor1)
+		r.read(&con.Anchor2)
+		r.read(&con.Min)
+		r.read(&con.Max)
+	case ConstraintDefPin:
+		r.read(&con.Anchor1)
+		r.read(&con.Anchor2)
+		r.read(&con.Dist)
+	case ConstraintDefRotaryLimit:
+		r.read(&con.Min)
+		r.read(&con.Max)
+	case ConstraintDefRatchet:
+		r.read(&con.Angle)
+		r.read(&con.Phase)
+		r.read(&con.Ratchet)
+	case ConstraintDefGear:
+		r.read(&con.Phase)
+		r.read(&con.Ratio)
+	case ConstraintDefSimpleMotor:
+		r.read(&con.Rate)
+	case ConstraintDefDampedRotarySpring:
+		r.read(&con.RestAngle)
+		r.read(&con.Stiffness)
+		r.read(&con.Damping)
+	}
+	return con
+}
@@ -0,0 +1,143 @@
+package chipmunk
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Returns a space with one constraint of every type between two bodies, the bodies have no ID.
+func newJointSpace() *Space {
+	space := NewSpace()
+	a := NewBody(1, 1)
+	a.AddShape(NewCircle(Vector_Zero, 1))
+	b := NewBody(2, 3)
+	b.AddShape(NewBox(Vector_Zero, 2, 2))
+	b.SetPosition(Vect{5, 1})
+	b.SetAngle(0.5)
+	space.AddBody(a)
+	space.AddBody(b)
+
+	pin := NewPinJoint(a, b, Vect{1, 0}, Vect{0, 1})
+	pin.Dist = 3
+	ratchet := NewRatchetJoint(a, b, 0.25, 0.5)
+	ratchet.Angle = 1.5
+	spring := NewDampedRotarySpring(a, b, 0.75, 10, 2)
+	spring.MaxForce = 100
+	spring.BreakImpulse = 50
+
+	constraints := []Constraint{
+		NewPivotJointAnchor(a, b, Vect{1, 2}, Vect{3, 4}),
+		NewDampedSpring(a, b, Vect{1, 0}, Vect{-1, 0}, 4, 20, 0.5),
+		NewGrooveJoint(a, b, Vect{-2, 0}, Vect{2, 0}, Vect{0, 1}),
+		NewSlideJoint(a, b, Vect{0, 1}, Vect{1, 0}, 1, 6),
+		pin,
+		NewRotaryLimitJoint(a, b, -1, 1),
+		ratchet,
+		NewGearJoint(a, b, 0.1, 2),
+		NewSimpleMotor(a, b, 3),
+		spring,
+	}
+	for _, constraint := range constraints {
+		space.AddConstraint(constraint)
+	}
+	return space
+}
+
+func TestSerializeConstraints(t *testing.T) {
+	space := newJointSpace()
+	def, err := space.Def()
+	if err != nil {
+		t.Fatal(err)
+	}
+	types := map[string]bool{}
+	for _, con := range def.Constraints {
+		types[con.Type] = true
+	}
+	if len(types) != 10 {
+		t.Fatalf("description has %d constraint types, want 10", len(types))
+	}
+	for _, body := range space.Bodies {
+		if body.ID != 0 {
+			t.Fatalf("Def() changed a body ID to %d", body.ID)
+		}
+	}
+
+	tests := []struct {
+		name      string
+		marshal   func() ([]byte, error)
+		unmarshal func([]byte) (*Space, map[uint32]*Body, error)
+	}{
+		{"JSON", space.MarshalJSON, UnmarshalSpaceJSON},
+		{"binary", space.MarshalBinary, UnmarshalSpaceBinary},
+	}
+	for _, test := range tests {
+		data, err := test.marshal()
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		loaded, _, err := test.unmarshal(data)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		loadedDef, err := loaded.Def()
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !reflect.DeepEqual(loadedDef, def) {
+			t.Errorf("%s: loaded space differs:\n%+v\nwant\n%+v", test.name, loadedDef.Constraints, def.Constraints)
+		}
+	}
+}
+
+func TestSerializePolygonWinding(t *testing.T) {
+	tests := []struct {
+		name      string
+		marshal   func(*Space) ([]byte, error)
+		unmarshal func([]byte) (*Space, map[uint32]*Body, error)
+	}{
+		{"JSON", (*Space).MarshalJSON, UnmarshalSpaceJSON},
+		{"binary", (*Space).MarshalBinary, UnmarshalSpaceBinary},
+	}
+	load := func(marshal func(*Space) ([]byte, error), unmarshal func([]byte) (*Space, map[uint32]*Body, error), verts Vertices) (*Shape, error) {
+		space := NewSpace()
+		body := NewBody(1, 1)
+		body.ID = 1
+		body.AddShape(NewPolygon(verts, Vector_Zero))
+		body.SetPosition(Vect{10, 0})
+		space.AddBody(body)
+		data, err := marshal(space)
+		if err != nil {
+			return nil, err
+		}
+		_, bodies, err := unmarshal(data)
+		if err != nil {
+			return nil, err
+		}
+		return bodies[1].Shapes[0], nil
+	}
+
+	for _, test := range tests {
+		// Counter-clockwise vertices are reversed.
+		shape, err := load(test.marshal, test.unmarshal, Vertices{{-1, -1}, {1, -1}, {1, 1}, {-1, 1}})
+		if err != nil {
+			t.Fatalf("%s: counter-clockwise polygon: %v", test.name, err)
+		}
+		poly := shape.GetAsPolygon()
+		if !poly.Verts.IsClockwise() {
+			t.Errorf("%s: loaded polygon is wound counter-clockwise", test.name)
+		}
+		if !poly.ContainsVert(Vect{10.5, 0.5}) || poly.ContainsVert(Vect{12, 0}) {
+			t.Errorf("%s: loaded polygon doesn't contain the right points", test.name)
+		}
+
+		invalid := []Vertices{
+			{{0, 0}, {0, 2}, {1, 1}, {2, 2}, {2, 0}},
+			{{0, 0}, {1, 0}, {2, 0}},
+		}
+		for _, verts := range invalid {
+			if _, err := load(test.marshal, test.unmarshal, verts); err != ErrInvalidData {
+				t.Errorf("%s: loading the polygon %v returned %v, want %v", test.name, verts, err, ErrInvalidData)
+			}
+		}
+	}
+}
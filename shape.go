@@ -13,6 +13,38 @@ const (
 type Group int
 type Layer int
 
+// Every category, the default categories and mask of shapes.
+const AllCategories = Layer(-1)
+
+// Filters the shapes that collide with each other and the shapes found by queries.
+type ShapeFilter struct {
+	// Shapes in the same non-zero group don't collide.
+	Group Group `json:"group"`
+	// Bitmask of the categories the shape is in.
+	Categories Layer `json:"categories"`
+	// Bitmask of the categories the shape collides with.
+	Mask Layer `json:"mask"`
+}
+
+var (
+	// Filter that collides with everything, the default filter of shapes.
+	ShapeFilterAll = ShapeFilter{Categories: AllCategories, Mask: AllCategories}
+	// Filter that collides with nothing.
+	ShapeFilterNone = ShapeFilter{Categories: ^AllCategories, Mask: ^AllCategories}
+)
+
+// Returns true if shapes with the two filters don't collide.
+// They collide if they aren't in the same non-zero group and each is in a category the other collides with.
+func (a ShapeFilter) Reject(b ShapeFilter) bool {
+	return (a.Group != 0 && a.Group == b.Group) || (a.Categories&b.Mask) == 0 || (b.Categories&a.Mask) == 0
+}
+
+// Returns the filter of shapes or queries with the given group and layers,
+// the layers are both the categories and the mask.
+func layerFilter(layers Layer, group Group) ShapeFilter {
+	return ShapeFilter{Group: group, Categories: layers, Mask: layers}
+}
+
 type Shape struct {
 	DefaultHash
 	ShapeClass
@@ -40,20 +72,57 @@ type Shape struct {
 	UserData interface{}
 
 	/// Collision type of this shape used when picking collision handlers.
-	//collision_type CollisionType
+	CollisionType CollisionType
 	/// Group of this shape. Shapes in the same group don't collide.
 	Group Group
 	// Layer bitmask for this shape. Shapes only collide if the bitwise and of their layers is non-zero.
 	Layer Layer
+	// Filters the shapes this shape collides with, on top of Group and Layer. Defaults to ShapeFilterAll.
+	// A non-zero Filter.Group is used instead of Group.
+	Filter ShapeFilter
 
 	space *Space
 
 	velocityIndexed bool
+
+	// Mass or density set with SetMass() or SetDensity(), only one of them is non-zero.
+	mass    float32
+	density float32
+}
+
+// Information about a segment query hit.
+type SegmentQueryInfo struct {
+	// The shape that was hit.
+	Shape *Shape
+	// The point where the segment hit the shape.
+	Point Vect
+	// The normal of the surface that was hit.
+	Normal Vect
+	// The normalized distance along the segment, 0 at the start and 1 at the end.
+	Alpha float32
+}
+
+func (info *SegmentQueryInfo) set(shape *Shape, a, b Vect, n Vect, t float32) {
+	info.Shape = shape
+	info.Point = Lerp(a, b, t)
+	info.Normal = n
+	info.Alpha = t
 }
 
 func newShape() *Shape {
-	return &Shape{velocityIndexed: true, e: 0.5, u: 0.5, Layer: -1}
+	return &Shape{velocityIndexed: true, e: 0.5, u: 0.5, Layer: -1, Filter: ShapeFilterAll}
+
+}
 
+// Returns Filter combined with the group and layers of the shape.
+func (shape *Shape) filter() ShapeFilter {
+	filter := shape.Filter
+	if filter.Group == 0 {
+		filter.Group = shape.Group
+	}
+	filter.Categories &= shape.Layer
+	filter.Mask &= shape.Layer
+	return filter
 }
 
 func (shape *Shape) Velocity() (Vect, bool) {
@@ -68,6 +137,63 @@ func (shape *Shape) SetElasticity(e float32) {
 	shape.e = e
 }
 
+// Sets the mass of the shape to its area times the density.
+// The mass and the moment of the body are recomputed from its shapes at the next step.
+// A density of zero removes the shape's mass, negative, infinite and NaN densities return ErrInvalidMass.
+func (shape *Shape) SetDensity(density float32) error {
+	if !validShapeMass(density) {
+		return ErrInvalidMass
+	}
+	shape.density = density
+	shape.mass = 0
+	shape.massChanged()
+	return nil
+}
+
+// Sets the mass of the shape.
+// The mass and the moment of the body are recomputed from its shapes at the next step.
+// A mass of zero removes the shape's mass, negative, infinite and NaN masses return ErrInvalidMass.
+func (shape *Shape) SetMass(mass float32) error {
+	if !validShapeMass(mass) {
+		return ErrInvalidMass
+	}
+	shape.mass = mass
+	shape.density = 0
+	shape.massChanged()
+	return nil
+}
+
+// Returns the mass of the shape, zero if it has no mass or density.
+func (shape *Shape) Mass() float32 {
+	if shape.density != 0 {
+		return shape.density * shape.area()
+	}
+	return shape.mass
+}
+
+// Returns the density of the shape, zero if it has no mass or density.
+func (shape *Shape) Density() float32 {
+	if shape.mass != 0 {
+		if area := shape.area(); area > 0 {
+			return shape.mass / area
+		}
+		return 0
+	}
+	return shape.density
+}
+
+// Returns true for zero and for positive finite masses and densities.
+func validShapeMass(m float32) bool {
+	return m == 0 || isPositive(m) && !math.IsInf(float64(m), 1)
+}
+
+func (shape *Shape) massChanged() {
+	if shape.Body != nil {
+		shape.Body.massDirty = true
+		shape.Body.BodyActivate()
+	}
+}
+
 func (shape *Shape) Shape() *Shape {
 	return shape
 }
@@ -86,7 +212,20 @@ func (shape *Shape) Clone() *Shape {
 	return cc
 }
 
+// Performs a segment query from a to b against the shape.
+// Returns true and fills info if the segment hits the shape.
+func (shape *Shape) SegmentQuery(a, b Vect, info *SegmentQueryInfo) bool {
+	var hit SegmentQueryInfo
+	if !shape.segmentQuery(a, b, 0, &hit) {
+		return false
+	}
+	if info != nil {
+		*info = hit
+	}
+	return true
+}
+
 func (shape *Shape) Update() {
 	//fmt.Println("Rot", shape.Body.rot)
-	shape.BB = shape.ShapeClass.update(NewTransform(shape.Body.p, shape.Body.a))
+	shape.BB = shape.ShapeClass.update(NewTransform(shape.Body.Position(), shape.Body.a))
 }
@@ -33,7 +33,16 @@ type ShapeClass interface {
 	// Returns if the given point is located inside the shape.
 	TestPoint(point Vect) bool
 
+	// Returns the moment of inertia of the shape around the center of gravity of its body.
 	Moment(mass float32) float32
+	// Returns the area of the shape.
+	area() float32
+	// Returns the centroid of the shape in body coordinates.
+	centroid() Vect
+
+	// Performs a segment query from a to b against the shape and fills info if it was hit.
+	// A radius greater than zero sweeps a circle of that radius along the segment.
+	segmentQuery(a, b Vect, r float32, info *SegmentQueryInfo) bool
 
 	Clone(s *Shape) ShapeClass
 	//marshalShape(shape *Shape) ([]byte, error)
@@ -0,0 +1,244 @@
+package chipmunk
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestShapeSegmentQuery(t *testing.T) {
+	square := Vertices{{-2, -2}, {-2, 2}, {2, 2}, {2, -2}}
+	tests := []struct {
+		name  string
+		shape *Shape
+		angle float32
+		point Vect
+		alpha float32
+	}{
+		{"circle", NewCircle(Vector_Zero, 2), 0, Vect{8, 0}, 0.4},
+		{"box", NewBox(Vector_Zero, 4, 4), 0, Vect{8, 0}, 0.4},
+		{"polygon", NewPolygon(square, Vector_Zero), 0, Vect{8, 0}, 0.4},
+		{"segment", NewSegment(Vect{-5, 0}, Vect{5, 0}, 0), math.Pi / 2, Vect{10, 0}, 0.5},
+		{"rounded segment", NewSegment(Vect{-5, 0}, Vect{5, 0}, 1), math.Pi / 2, Vect{9, 0}, 0.45},
+	}
+	for _, test := range tests {
+		body := NewBody(1, 1)
+		body.AddShape(test.shape)
+		body.SetPosition(Vect{10, 0})
+		body.SetAngle(test.angle)
+		body.UpdateShapes()
+
+		var info SegmentQueryInfo
+		if !test.shape.SegmentQuery(Vect{0, 0}, Vect{20, 0}, &info) {
+			t.Errorf("%s: segment didn't hit the shape", test.name)
+			continue
+		}
+		if info.Shape != test.shape || Dist(info.Point, test.point) > 1e-4 || FAbs(info.Alpha-test.alpha) > 1e-4 {
+			t.Errorf("%s: hit at %v with alpha %v, want %v with alpha %v", test.name, info.Point, info.Alpha, test.point, test.alpha)
+		}
+		if Dist(info.Normal, Vect{-1, 0}) > 1e-4 {
+			t.Errorf("%s: normal is %v, want (-1, 0)", test.name, info.Normal)
+		}
+
+		if test.shape.SegmentQuery(Vect{0, 8}, Vect{20, 8}, nil) {
+			t.Errorf("%s: segment passing above the shape hit it", test.name)
+		}
+		if test.shape.SegmentQuery(Vect{0, 0}, Vect{5, 0}, nil) {
+			t.Errorf("%s: segment ending before the shape hit it", test.name)
+		}
+	}
+}
+
+func TestShapeFilterReject(t *testing.T) {
+	const (
+		player Layer = 1 << iota
+		enemy
+		playerBullet
+	)
+	playerFilter := ShapeFilter{Group: 1, Categories: player, Mask: AllCategories}
+	enemyFilter := ShapeFilter{Categories: enemy, Mask: AllCategories}
+	bulletFilter := ShapeFilter{Group: 1, Categories: playerBullet, Mask: AllCategories &^ playerBullet}
+
+	tests := []struct {
+		name   string
+		a, b   ShapeFilter
+		reject bool
+	}{
+		{"bullet hits enemy", bulletFilter, enemyFilter, false},
+		{"bullet misses bullet", bulletFilter, bulletFilter, true},
+		{"bullet misses player", bulletFilter, playerFilter, true},
+		{"player hits enemy", playerFilter, enemyFilter, false},
+		{"everything", ShapeFilterAll, enemyFilter, false},
+		{"nothing", ShapeFilterNone, ShapeFilterAll, true},
+	}
+	for _, test := range tests {
+		if test.a.Reject(test.b) != test.reject || test.b.Reject(test.a) != test.reject {
+			t.Errorf("%s: Reject() = %v, want %v", test.name, test.a.Reject(test.b), test.reject)
+		}
+	}
+
+	// Only the enemy wants to collide with the player, both must agree.
+	shy := ShapeFilter{Categories: enemy, Mask: enemy}
+	if !shy.Reject(playerFilter) {
+		t.Error("one-sided mask didn't reject")
+	}
+}
+
+func TestSpaceShouldCollide(t *testing.T) {
+	space, _, ball := newPlatformSpace(NewBBTree, 0)
+	stepSpace(space, 30)
+	if y := ball.Position().Y; y < 5 {
+		t.Fatalf("ball fell through the platform: %v", y)
+	}
+
+	space.ShouldCollide = func(a, b *Shape) bool {
+		return a.Body != ball && b.Body != ball
+	}
+	stepSpace(space, 60)
+	if y := ball.Position().Y; y > -100 {
+		t.Fatalf("ball is still at %v when it shouldn't collide", y)
+	}
+}
+
+func TestShapeLayersAndFilter(t *testing.T) {
+	a, b := NewCircle(Vector_Zero, 1), NewCircle(Vector_Zero, 1)
+	a.Body, b.Body = NewBody(1, 1), NewBody(1, 1)
+	a.BB, b.BB = a.update(NewTransform(Vector_Zero, 0)), b.update(NewTransform(Vector_Zero, 0))
+	if queryReject(a, b) {
+		t.Fatal("default shapes don't collide")
+	}
+
+	a.Layer, b.Layer = 1, 2
+	if !queryReject(a, b) {
+		t.Error("shapes in different layers collide")
+	}
+	b.Layer = 3
+	a.Filter.Mask = 2
+	if !queryReject(a, b) {
+		t.Error("filter mask wasn't combined with the layers")
+	}
+
+	a.Filter.Mask = AllCategories
+	a.Group, b.Group = 5, 5
+	if !queryReject(a, b) {
+		t.Error("shapes in the same group collide")
+	}
+	b.Filter.Group = 6
+	if queryReject(a, b) {
+		t.Error("Filter.Group didn't override Group")
+	}
+}
+
+func TestSpaceQueryLayers(t *testing.T) {
+	space := NewSpace()
+	body := NewBodyStatic()
+	shape := NewCircle(Vector_Zero, 5)
+	shape.Layer = 2
+	shape.Group = 1
+	body.AddShape(shape)
+	space.AddBody(body)
+
+	tests := []struct {
+		name   string
+		layers Layer
+		group  Group
+		filter ShapeFilter
+		hit    bool
+	}{
+		{"all layers", -1, 0, ShapeFilterAll, true},
+		{"other layer", 1, 0, ShapeFilter{Categories: 1, Mask: 1}, false},
+		{"same group", -1, 1, ShapeFilter{Group: 1, Categories: AllCategories, Mask: AllCategories}, false},
+	}
+	for _, test := range tests {
+		if hit := space.SpacePointQueryFirst(Vector_Zero, test.layers, test.group, false) != nil; hit != test.hit {
+			t.Errorf("%s: SpacePointQueryFirst() hit = %v", test.name, hit)
+		}
+		if hit := space.SpacePointQueryFirstWithFilter(Vector_Zero, test.filter, false) != nil; hit != test.hit {
+			t.Errorf("%s: SpacePointQueryFirstWithFilter() hit = %v", test.name, hit)
+		}
+		if hit := space.SegmentQueryFirst(Vect{-10, 0}, Vect{10, 0}, test.layers, test.group, false) != nil; hit != test.hit {
+			t.Errorf("%s: SegmentQueryFirst() hit = %v", test.name, hit)
+		}
+		if hit := space.SegmentQueryFirstWithFilter(Vect{-10, 0}, Vect{10, 0}, test.filter, false) != nil; hit != test.hit {
+			t.Errorf("%s: SegmentQueryFirstWithFilter() hit = %v", test.name, hit)
+		}
+	}
+}
+
+func TestShapeDefFilterDefaults(t *testing.T) {
+	var def ShapeDef
+	if err := json.Unmarshal([]byte(`{"type":"circle","radius":1}`), &def); err != nil {
+		t.Fatal(err)
+	}
+	if def.Layer != -1 || def.Filter != ShapeFilterAll {
+		t.Fatalf("default layer %v and filter %v", def.Layer, def.Filter)
+	}
+}
+
+func TestOneWayPlatform(t *testing.T) {
+	const platformType CollisionType = 1
+	space := NewSpace()
+	space.Gravity = Vect{0, -600}
+	space.SetEnableContactGraph(true)
+
+	platform := NewBodyStatic()
+	segment := NewSegment(Vect{-50, 0}, Vect{50, 0}, 1)
+	segment.CollisionType = platformType
+	platform.AddShape(segment)
+	space.AddBody(platform)
+
+	handler := space.AddWildcardHandler(platformType)
+	handler.PreSolve = OneWayPlatform
+	firstContacts, removals := 0, 0
+	handler.Begin = func(arb *Arbiter, space *Space) bool {
+		if arb.IsFirstContact() {
+			firstContacts++
+		}
+		return true
+	}
+	handler.Separate = func(arb *Arbiter, space *Space) {
+		if arb.IsRemoval() {
+			removals++
+		}
+	}
+
+	ball := NewBody(1, 10)
+	ball.AddShape(NewCircle(Vector_Zero, 5))
+	ball.SetPosition(Vect{0, -20})
+	ball.SetVelocity(0, 400)
+	space.AddBody(ball)
+
+	sawTop := false
+	for i := 0; i < 120; i++ {
+		space.Step(1.0 / 60.0)
+		sawTop = sawTop || ball.Position().Y > 10
+	}
+	if !sawTop {
+		t.Fatal("ball didn't pass through the platform from below")
+	}
+	if y := ball.Position().Y; y < 4 || y > 7 {
+		t.Fatalf("ball didn't land on the platform: %v", y)
+	}
+
+	var arb *Arbiter
+	ball.EachArbiter(func(edge *ArbiterEdge) { arb = edge.Arbiter })
+	if arb == nil {
+		t.Fatal("ball has no arbiter with the platform")
+	}
+	mine, other, n := arb.ShapesFor(ball)
+	if mine.Body != ball || other != segment {
+		t.Fatal("ShapesFor() didn't return the ball's shape first")
+	}
+	if n.Y > -0.9 {
+		t.Fatalf("normal from the ball = %v, want pointing down", n)
+	}
+	if arb.Normal().Y < 0.9 {
+		t.Fatalf("normal from the platform = %v, want pointing up", arb.Normal())
+	}
+
+	space.RemoveBody(ball)
+	space.Step(1.0 / 60.0)
+	if firstContacts < 2 || removals != 1 {
+		t.Fatalf("first contacts = %d, removals = %d", firstContacts, removals)
+	}
+}
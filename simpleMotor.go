@@ -0,0 +1,57 @@
+package chipmunk
+
+// Keeps the relative angular velocity of two bodies at Rate.
+type SimpleMotor struct {
+	BasicConstraint
+	Rate float32
+
+	iSum float32
+
+	jAcc, jMax float32
+}
+
+func NewSimpleMotor(a, b *Body, rate float32) *SimpleMotor {
+	return &SimpleMotor{
+		BasicConstraint: NewConstraint(a, b),
+		Rate:            rate,
+	}
+}
+
+func (this *SimpleMotor) PreStep(dt float32) {
+	a, b := this.BodyA, this.BodyB
+
+	// calculate moment of inertia coefficient.
+	this.iSum = 1 / (a.i_inv + b.i_inv)
+
+	// compute max impulse
+	this.jMax = this.MaxForce * dt
+}
+
+func (this *SimpleMotor) ApplyCachedImpulse(dt_coef float32) {
+	a, b := this.BodyA, this.BodyB
+
+	j := this.jAcc * dt_coef
+	a.w -= j * a.i_inv
+	b.w += j * b.i_inv
+}
+
+func (this *SimpleMotor) ApplyImpulse() {
+	a, b := this.BodyA, this.BodyB
+
+	// compute relative rotational velocity
+	wr := b.w - a.w + this.Rate
+
+	// compute normal impulse
+	j := -wr * this.iSum
+	jOld := this.jAcc
+	this.jAcc = FClamp(jOld+j, -this.jMax, this.jMax)
+	j = this.jAcc - jOld
+
+	// apply impulse
+	a.w -= j * a.i_inv
+	b.w += j * b.i_inv
+}
+
+func (this *SimpleMotor) Impulse() float32 {
+	return FAbs(this.jAcc)
+}
@@ -0,0 +1,85 @@
+package chipmunk
+
+// Keeps the distance between the anchor points of two bodies between Min and Max.
+type SlideJoint struct {
+	BasicConstraint
+	Anchor1, Anchor2 Vect
+	Min, Max         float32
+
+	r1, r2 Vect
+	n      Vect
+	nMass  float32
+
+	jnAcc, jnMax float32
+	bias         float32
+}
+
+func NewSlideJoint(a, b *Body, anchor1, anchor2 Vect, min, max float32) *SlideJoint {
+	return &SlideJoint{
+		BasicConstraint: NewConstraint(a, b),
+		Anchor1:         anchor1,
+		Anchor2:         anchor2,
+		Min:             min,
+		Max:             max,
+	}
+}
+
+func (this *SlideJoint) PreStep(dt float32) {
+	a, b := this.BodyA, this.BodyB
+
+	this.r1 = RotateVect(Sub(this.Anchor1, a.cog), Rotation{a.rot.X, a.rot.Y})
+	this.r2 = RotateVect(Sub(this.Anchor2, b.cog), Rotation{b.rot.X, b.rot.Y})
+
+	delta := Sub(Add(b.p, this.r2), Add(a.p, this.r1))
+	dist := Length(delta)
+	pdist := float32(0)
+	if dist > this.Max {
+		pdist = dist - this.Max
+		this.n = NormalizeSafe(delta)
+	} else if dist < this.Min {
+		pdist = this.Min - dist
+		this.n = Mult(NormalizeSafe(delta), -1)
+	} else {
+		this.n = Vector_Zero
+		this.jnAcc = 0
+	}
+
+	// calculate mass normal
+	this.nMass = 1 / k_scalar(a, b, this.r1, this.r2, this.n)
+
+	// compute max impulse
+	this.jnMax = this.MaxForce * dt
+
+	// calculate bias velocity
+	this.bias = FClamp(-bias_coef(this.ErrorBias, dt)*pdist/dt, -this.MaxBias, this.MaxBias)
+}
+
+func (this *SlideJoint) ApplyCachedImpulse(dt_coef float32) {
+	j := Mult(this.n, this.jnAcc*dt_coef)
+	apply_impulses(this.BodyA, this.BodyB, this.r1, this.r2, j)
+}
+
+func (this *SlideJoint) ApplyImpulse() {
+	if Equals(this.n, Vector_Zero) {
+		return // early exit
+	}
+
+	a, b := this.BodyA, this.BodyB
+	n := this.n
+
+	// compute relative velocity
+	vrn := normal_relative_velocity(a, b, this.r1, this.r2, n)
+
+	// compute normal impulse
+	jn := (this.bias - vrn) * this.nMass
+	jnOld := this.jnAcc
+	this.jnAcc = FClamp(jnOld+jn, -this.jnMax, 0)
+	jn = this.jnAcc - jnOld
+
+	// apply impulse
+	apply_impulses(a, b, this.r1, this.r2, Mult(n, jn))
+}
+
+func (this *SlideJoint) Impulse() float32 {
+	return FAbs(this.jnAcc)
+}
@@ -0,0 +1,401 @@
+package chipmunk
+
+import (
+	"reflect"
+	"time"
+)
+
+// The saved state of a space and of the bodies, shapes, constraints and arbiters in it.
+// Created with Space.Snapshot() and applied with Space.Restore().
+type Snapshot struct {
+	space *Space
+
+	iterations           int
+	gravity              Vect
+	linearDamping        float32
+	angularDamping       float32
+	idleSpeedThreshold   float32
+	sleepTimeThreshold   float32
+	collisionSlop        float32
+	collisionBias        float32
+	collisionPersistence int64
+	enableContactGraph   bool
+	curr_dt              float32
+	accumulator          float32
+	stamp                time.Duration
+
+	bodies             []*Body
+	sleepingComponents []*Body
+	deleteBodies       []*Body
+	constraints        []Constraint
+	arbiters           []*Arbiter
+
+	bodyStates       []bodySnapshot
+	shapeStates      []shapeSnapshot
+	constraintStates []constraintSnapshot
+	arbiterStates    []arbiterSnapshot
+
+	shapeIndexes interface{}
+}
+
+type bodySnapshot struct {
+	body   *Body
+	state  Body
+	shapes []*Shape
+}
+
+type shapeSnapshot struct {
+	shape *Shape
+	state Shape
+	class ShapeClass
+}
+
+type constraintSnapshot struct {
+	constraint Constraint
+	state      reflect.Value
+}
+
+type arbiterSnapshot struct {
+	arb          *Arbiter
+	state        Arbiter
+	nodeA, nodeB ArbiterEdge
+	contacts     []Contact
+
+	cached bool
+	key    HashPair
+}
+
+// Implemented by dynamic spatial indexes that can save and restore their exact state
+// together with the state of their static index.
+// Other indexes are rebuilt on restore, which may change the order of the collisions.
+type spatialIndexSnapshotter interface {
+	snapshot() interface{}
+	restore(state interface{})
+}
+
+// Saves the state of the space so it can be restored with Restore().
+// Stepping the space after restoring gives the same results as stepping it after taking the snapshot.
+// Collision handlers and post-step callbacks are not part of the snapshot.
+func (space *Space) Snapshot() (*Snapshot, error) {
+	if space.locked != 0 {
+		return nil, ErrSpaceLocked
+	}
+
+	snapshot := &Snapshot{
+		space:                space,
+		iterations:           space.Iterations,
+		gravity:              space.Gravity,
+		linearDamping:        space.LinearDamping,
+		angularDamping:       space.AngularDamping,
+		idleSpeedThreshold:   space.idleSpeedThreshold,
+		sleepTimeThreshold:   space.sleepTimeThreshold,
+		collisionSlop:        space.collisionSlop,
+		collisionBias:        space.collisionBias,
+		collisionPersistence: space.collisionPersistence,
+		enableContactGraph:   space.enableContactGraph,
+		curr_dt:              space.curr_dt,
+		accumulator:          space.accumulator,
+		stamp:                space.stamp,
+
+		bodies:             append([]*Body(nil), space.Bodies...),
+		sleepingComponents: append([]*Body(nil), space.sleepingComponents...),
+		deleteBodies:       append([]*Body(nil), space.deleteBodies...),
+		constraints:        append([]Constraint(nil), space.Constraints...),
+		arbiters:           append([]*Arbiter(nil), space.Arbiters...),
+	}
+
+	bodies, shapes, constraints, arbiters := space.contents()
+
+	for _, body := range bodies {
+		snapshot.bodyStates = append(snapshot.bodyStates, bodySnapshot{body, *body, append([]*Shape(nil), body.Shapes...)})
+	}
+
+	for _, shape := range shapes {
+		snapshot.shapeStates = append(snapshot.shapeStates, shapeSnapshot{shape, *shape, shape.ShapeClass.Clone(shape)})
+	}
+
+	for _, constraint := range constraints {
+		v := reflect.ValueOf(constraint).Elem()
+		state := reflect.New(v.Type()).Elem()
+		state.Set(v)
+		snapshot.constraintStates = append(snapshot.constraintStates, constraintSnapshot{constraint, state})
+	}
+
+	for _, arb := range arbiters {
+		state := arbiterSnapshot{arb: arb, state: *arb, nodeA: *arb.nodeA, nodeB: *arb.nodeB}
+		if arb.Contacts != nil {
+			state.contacts = make([]Contact, len(arb.Contacts))
+			for i, con := range arb.Contacts {
+				state.contacts[i] = *con
+			}
+		}
+		if arb.ShapeA != nil && arb.ShapeB != nil {
+			state.key = newPair(arb.ShapeA, arb.ShapeB)
+			state.cached = space.cachedArbiters[state.key] == arb
+		}
+		snapshot.arbiterStates = append(snapshot.arbiterStates, state)
+	}
+
+	snapshot.shapeIndexes = space.snapshotIndexes()
+
+	return snapshot, nil
+}
+
+// Restores the state saved by Snapshot(). The bodies, shapes and constraints keep their identity,
+// objects added after the snapshot are removed from the space and removed objects are added back.
+// A snapshot can be restored any number of times, but only to the space it was taken from.
+func (space *Space) Restore(snapshot *Snapshot) error {
+	if snapshot == nil {
+		return ErrNilObject
+	}
+	if snapshot.space != space {
+		return ErrNotInSpace
+	}
+	if space.locked != 0 {
+		return ErrSpaceLocked
+	}
+
+	// Detach everything that is in the space now, the snapshot attaches its objects again.
+	bodies, shapes, constraints, arbiters := space.contents()
+	for _, body := range bodies {
+		body.space = nil
+	}
+	for _, shape := range shapes {
+		shape.space = nil
+	}
+	for _, constraint := range constraints {
+		constraint.Constraint().space = nil
+	}
+
+	space.Iterations = snapshot.iterations
+	space.Gravity = snapshot.gravity
+	space.LinearDamping = snapshot.linearDamping
+	space.AngularDamping = snapshot.angularDamping
+	space.idleSpeedThreshold = snapshot.idleSpeedThreshold
+	space.sleepTimeThreshold = snapshot.sleepTimeThreshold
+	space.collisionSlop = snapshot.collisionSlop
+	space.collisionBias = snapshot.collisionBias
+	space.collisionPersistence = snapshot.collisionPersistence
+	space.enableContactGraph = snapshot.enableContactGraph
+	space.curr_dt = snapshot.curr_dt
+	space.accumulator = snapshot.accumulator
+	space.stamp = snapshot.stamp
+
+	space.Bodies = append(space.Bodies[:0], snapshot.bodies...)
+	space.sleepingComponents = append(space.sleepingComponents[:0], snapshot.sleepingComponents...)
+	space.deleteBodies = append(space.deleteBodies[:0], snapshot.deleteBodies...)
+	space.Constraints = append(space.Constraints[:0], snapshot.constraints...)
+	space.Arbiters = append(space.Arbiters[:0], snapshot.arbiters...)
+
+	for _, state := range snapshot.bodyStates {
+		*state.body = state.state
+		state.body.Shapes = append([]*Shape(nil), state.shapes...)
+	}
+
+	for _, state := range snapshot.shapeStates {
+		*state.shape = state.state
+		copyShapeClass(state.shape.ShapeClass, state.class)
+	}
+
+	for _, state := range snapshot.constraintStates {
+		reflect.ValueOf(state.constraint).Elem().Set(state.state)
+	}
+
+	// The current arbiters go back to the pools, the restored arbiters take theirs out again.
+	pooled := make(map[*Arbiter]bool, len(space.ArbiterBuffer))
+	for _, arb := range space.ArbiterBuffer {
+		pooled[arb] = true
+	}
+	for _, arb := range arbiters {
+		// Arbiters of removed bodies may be recycled while they are still in space.Arbiters.
+		if pooled[arb] {
+			continue
+		}
+		if arb.Contacts != nil {
+			space.pushContactBuffer(arb.Contacts)
+			arb.Contacts = nil
+		}
+		space.ArbiterBuffer = append(space.ArbiterBuffer, arb)
+		pooled[arb] = true
+	}
+
+	space.cachedArbiters = make(map[HashPair]*Arbiter, len(snapshot.arbiterStates))
+	restored := make(map[*Arbiter]bool, len(snapshot.arbiterStates))
+	for _, state := range snapshot.arbiterStates {
+		arb := state.arb
+		*arb = state.state
+		*arb.nodeA = state.nodeA
+		*arb.nodeB = state.nodeB
+
+		if state.contacts != nil {
+			contacts := space.pullContactBuffer()[:MaxPoints]
+			for i, con := range state.contacts {
+				*contacts[i] = con
+			}
+			arb.Contacts = contacts[:len(state.contacts)]
+		}
+
+		if state.cached {
+			space.cachedArbiters[state.key] = arb
+		}
+		restored[arb] = true
+	}
+
+	// The restored arbiters may also have been recycled since the snapshot was taken.
+	buffer := space.ArbiterBuffer[:0]
+	for _, arb := range space.ArbiterBuffer {
+		if !restored[arb] {
+			buffer = append(buffer, arb)
+		}
+	}
+	for i := len(buffer); i < len(space.ArbiterBuffer); i++ {
+		space.ArbiterBuffer[i] = nil
+	}
+	space.ArbiterBuffer = buffer
+
+	space.restoreIndexes(snapshot.shapeIndexes)
+
+	return nil
+}
+
+// Returns every body, shape, constraint and arbiter in the space, including sleeping ones.
+func (space *Space) contents() (bodies []*Body, shapes []*Shape, constraints []Constraint, arbiters []*Arbiter) {
+	seenBodies := make(map[*Body]bool)
+	addBody := func(body *Body) {
+		if body != nil && !seenBodies[body] {
+			seenBodies[body] = true
+			bodies = append(bodies, body)
+		}
+	}
+
+	seenShapes := make(map[*Shape]bool)
+	addShape := func(obj Indexable) {
+		shape := obj.Shape()
+		if !seenShapes[shape] {
+			seenShapes[shape] = true
+			shapes = append(shapes, shape)
+			addBody(shape.Body)
+		}
+	}
+
+	for _, body := range space.Bodies {
+		addBody(body)
+	}
+	for _, root := range space.sleepingComponents {
+		for body := root; body != nil; body = body.node.Next {
+			addBody(body)
+		}
+	}
+	for _, body := range space.deleteBodies {
+		addBody(body)
+	}
+	space.staticShapes.Each(func(node *Node) { addShape(node.obj) })
+	space.activeShapes.Each(func(node *Node) { addShape(node.obj) })
+
+	seenConstraints := make(map[Constraint]bool)
+	addConstraint := func(constraint Constraint) {
+		if !seenConstraints[constraint] {
+			seenConstraints[constraint] = true
+			constraints = append(constraints, constraint)
+			con := constraint.Constraint()
+			addBody(con.BodyA)
+			addBody(con.BodyB)
+		}
+	}
+
+	for _, constraint := range space.Constraints {
+		addConstraint(constraint)
+	}
+	// Constraints of sleeping bodies are only found in the constraint lists of the bodies.
+	// bodies grows while iterating when a constraint is attached to a body that wasn't found yet.
+	for i := 0; i < len(bodies); i++ {
+		body := bodies[i]
+		for constraint := body.constraintList; constraint != nil; constraint = constraint.Constraint().next(body) {
+			addConstraint(constraint)
+		}
+	}
+
+	seenArbiters := make(map[*Arbiter]bool)
+	addArbiter := func(arb *Arbiter) {
+		if !seenArbiters[arb] {
+			seenArbiters[arb] = true
+			arbiters = append(arbiters, arb)
+		}
+	}
+
+	for _, arb := range space.Arbiters {
+		addArbiter(arb)
+	}
+	for _, arb := range space.cachedArbiters {
+		addArbiter(arb)
+	}
+	// Arbiters of sleeping bodies are only found in the contact graph.
+	for _, body := range bodies {
+		for edge := body.arbiterList; edge != nil; edge = edge.Next {
+			addArbiter(edge.Arbiter)
+		}
+	}
+
+	return
+}
+
+func (space *Space) snapshotIndexes() interface{} {
+	if snapshotter, ok := space.activeShapes.SpatialIndexClass.(spatialIndexSnapshotter); ok {
+		return snapshotter.snapshot()
+	}
+	return [2][]Indexable{indexObjects(space.staticShapes), indexObjects(space.activeShapes)}
+}
+
+func (space *Space) restoreIndexes(state interface{}) {
+	if snapshotter, ok := space.activeShapes.SpatialIndexClass.(spatialIndexSnapshotter); ok {
+		snapshotter.restore(state)
+		return
+	}
+
+	objs := state.([2][]Indexable)
+	for i, index := range []*SpatialIndex{space.staticShapes, space.activeShapes} {
+		for _, obj := range indexObjects(index) {
+			index.Remove(obj)
+		}
+		for _, obj := range objs[i] {
+			index.Insert(obj)
+		}
+	}
+}
+
+func indexObjects(index *SpatialIndex) (objs []Indexable) {
+	index.Each(func(node *Node) {
+		objs = append(objs, node.obj)
+	})
+	return
+}
+
+// Copies the state of src into dst, both must be the same type of shape.
+// The vertex slices of polygons are copied so dst doesn't share them with src.
+func copyShapeClass(dst, src ShapeClass) {
+	switch src := src.(type) {
+	case *CircleShape:
+		*dst.(*CircleShape) = *src
+	case *SegmentShape:
+		*dst.(*SegmentShape) = *src
+	case *PolygonShape:
+		copyPolygon(dst.(*PolygonShape), src)
+	case *BoxShape:
+		box := dst.(*BoxShape)
+		poly := box.Polygon
+		*box = *src
+		box.Polygon = poly
+		copyPolygon(poly, src.Polygon)
+	default:
+		reflect.ValueOf(dst).Elem().Set(reflect.ValueOf(src).Elem())
+	}
+}
+
+func copyPolygon(dst, src *PolygonShape) {
+	verts, tVerts, axes, tAxes := dst.Verts, dst.TVerts, dst.Axes, dst.TAxes
+	*dst = *src
+	dst.Verts = append(verts[:0], src.Verts...)
+	dst.TVerts = append(tVerts[:0], src.TVerts...)
+	dst.Axes = append(axes[:0], src.Axes...)
+	dst.TAxes = append(tAxes[:0], src.TAxes...)
+}
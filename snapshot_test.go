@@ -0,0 +1,143 @@
+package chipmunk
+
+import (
+	"testing"
+)
+
+func newSnapshotTestSpace() (*Space, []*Body) {
+	space := NewSpace()
+	space.Gravity = Vect{0, -600}
+	space.SetSleepTimeThreshold(0.5)
+
+	ground := NewBodyStatic()
+	ground.AddShape(NewSegment(Vect{-400, 0}, Vect{400, 0}, 2))
+	space.AddBody(ground)
+
+	var bodies []*Body
+	for i := 0; i < 6; i++ {
+		body := NewBody(1, 50)
+		body.AddShape(NewBox(Vector_Zero, 20, 20))
+		body.SetPosition(Vect{float32(i % 2), 12 + float32(i)*21})
+		space.AddBody(body)
+		bodies = append(bodies, body)
+	}
+
+	for i := 0; i < 4; i++ {
+		body := NewBody(1, 20)
+		body.AddShape(NewCircle(Vector_Zero, 8))
+		body.SetPosition(Vect{100 + float32(i)*3, 30 + float32(i)*20})
+		space.AddBody(body)
+		bodies = append(bodies, body)
+	}
+
+	pendulum := NewBody(2, 100)
+	pendulum.AddShape(NewSegment(Vect{-10, 0}, Vect{10, 0}, 4))
+	pendulum.SetPosition(Vect{-150, 200})
+	space.AddBody(pendulum)
+	space.AddConstraint(NewPinJoint(ground, pendulum, Vect{-100, 250}, Vector_Zero))
+	bodies = append(bodies, pendulum)
+
+	return space, bodies
+}
+
+func bodyStates(bodies []*Body) []float32 {
+	var states []float32
+	for _, body := range bodies {
+		p, v := body.Position(), body.Velocity()
+		states = append(states, p.X, p.Y, v.X, v.Y, body.Angle(), body.AngularVelocity())
+	}
+	return states
+}
+
+func TestSnapshotRestoreDeterminism(t *testing.T) {
+	space, bodies := newSnapshotTestSpace()
+	stepSpace(space, 30)
+
+	snapshot, err := space.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot() returned %v", err)
+	}
+	start := bodyStates(bodies)
+
+	stepSpace(space, 200)
+	want := bodyStates(bodies)
+
+	for run := 0; run < 2; run++ {
+		if err := space.Restore(snapshot); err != nil {
+			t.Fatalf("Restore() returned %v", err)
+		}
+
+		got := bodyStates(bodies)
+		for i := range start {
+			if got[i] != start[i] {
+				t.Fatalf("run %d: state %d after Restore() = %v, want %v.", run, i, got[i], start[i])
+			}
+		}
+
+		stepSpace(space, 200)
+		got = bodyStates(bodies)
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("run %d: state %d after re-stepping = %v, want %v.", run, i, got[i], want[i])
+			}
+		}
+	}
+}
+
+func TestSnapshotRestoreMembership(t *testing.T) {
+	space, bodies := newSnapshotTestSpace()
+	stepSpace(space, 10)
+
+	snapshot, _ := space.Snapshot()
+
+	removed := bodies[0]
+	space.RemoveBody(removed)
+	added := NewBody(1, 1)
+	added.AddShape(NewCircle(Vector_Zero, 5))
+	space.AddBody(added)
+	stepSpace(space, 10)
+
+	if err := space.Restore(snapshot); err != nil {
+		t.Fatalf("Restore() returned %v", err)
+	}
+
+	if removed.space != space || removed.Shapes[0].space != space {
+		t.Errorf("removed body is not back in the space after Restore()")
+	}
+	if added.space != nil || added.Shapes[0].space != nil {
+		t.Errorf("added body is still in the space after Restore()")
+	}
+	if err := space.TryRemoveBody(added); err != ErrNotInSpace {
+		t.Errorf("TryRemoveBody() of the added body = %v, want %v.", err, ErrNotInSpace)
+	}
+
+	other := NewSpace()
+	if err := other.Restore(snapshot); err != ErrNotInSpace {
+		t.Errorf("Restore() to another space = %v, want %v.", err, ErrNotInSpace)
+	}
+
+	// The space must still simulate after restoring.
+	stepSpace(space, 10)
+}
+
+func TestSnapshotRestoreKeepsPools(t *testing.T) {
+	space, _ := newSnapshotTestSpace()
+	// No arbiters exist yet, the ones created later must all go back to the pools.
+	snapshot, _ := space.Snapshot()
+
+	var arbiters, contacts int
+	for run := 0; run < 3; run++ {
+		stepSpace(space, 60)
+		if err := space.Restore(snapshot); err != nil {
+			t.Fatalf("Restore() returned %v", err)
+		}
+		if run == 0 {
+			arbiters, contacts = len(space.ArbiterBuffer), len(space.ContactBuffer)
+			continue
+		}
+		if len(space.ArbiterBuffer) != arbiters || len(space.ContactBuffer) != contacts {
+			t.Fatalf("run %d: pools have %d arbiters and %d contacts, want %d and %d",
+				run, len(space.ArbiterBuffer), len(space.ContactBuffer), arbiters, contacts)
+		}
+	}
+}
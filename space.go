@@ -3,9 +3,11 @@ package chipmunk
 import (
 	"errors"
 	"fmt"
+	"log"
 
 	//"github.com/davecgh/go-spew/spew"
 	"math"
+	"sort"
 	"time"
 )
 
@@ -53,18 +55,37 @@ type Space struct {
 	/// Defaults to 3. There is probably never a reason to change this value.
 	collisionPersistence int64
 
-	/// Rebuild the contact graph during each step. Must be enabled to use the cpBodyEachArbiter() function.
+	/// Rebuild the contact graph during each step. Must be enabled to use the Body.EachArbiter() function.
 	/// Disabled by default for a small performance boost. Enabled implicitly when the sleeping feature is enabled.
 	enableContactGraph bool
 
 	curr_dt float32
 
-	Constraints []Constraint
+	/// Timestep of the substeps run by Update().
+	/// Defaults to 1/60 of a second.
+	FixedTimestep float32
+
+	/// Maximum number of substeps a single call to Update() runs to catch up.
+	/// Time beyond that is dropped so a long frame can't stall the simulation.
+	/// Zero or less runs as many substeps as needed. Defaults to 5.
+	MaxSubsteps int
+
+	// Elapsed time not yet simulated by Update().
+	accumulator float32
+
+	Constraints       []Constraint
+	brokenConstraints []brokenConstraint
 
 	Bodies             []*Body
 	sleepingComponents []*Body
+	rousedBodies       []*Body
 	deleteBodies       []*Body
 
+	postStepCallbacks []postStepCallback
+
+	// Greater than zero while the space is stepping or running a query.
+	locked int
+
 	stamp time.Duration
 
 	staticShapes *SpatialIndex
@@ -73,9 +94,16 @@ type Space struct {
 	cachedArbiters map[HashPair]*Arbiter
 	Arbiters       []*Arbiter
 
+	typeHandlers     map[collisionTypePair]*CollisionHandler
+	wildcardHandlers map[CollisionType]*CollisionHandler
+
 	ArbiterBuffer []*Arbiter
 	ContactBuffer [][]*Contact
 
+	// Called for every pair of shapes with overlapping bounding boxes that passed their filters,
+	// before their collision is computed. The shapes don't collide if it returns false.
+	ShouldCollide func(a, b *Shape) bool
+
 	ApplyImpulsesTime time.Duration
 	ReindexQueryTime  time.Duration
 	StepTime          time.Duration
@@ -93,6 +121,16 @@ type ContactBuffer struct {
 }
 
 func NewSpace() (space *Space) {
+	return NewSpaceWithIndex(NewBBTree)
+}
+
+// Creates a space that indexes its static and active shapes with indexes created by newIndex.
+// For example to use a spatial hash:
+//
+//	space := NewSpaceWithIndex(func(staticIndex *SpatialIndex) *SpatialIndex {
+//		return NewSpaceHash(20, 1000, staticIndex)
+//	})
+func NewSpaceWithIndex(newIndex SpatialIndexFunc) (space *Space) {
 
 	space = &Space{}
 	space.Iterations = 20
@@ -106,16 +144,25 @@ func NewSpace() (space *Space) {
 	space.collisionBias = float32(math.Pow(1.0-0.1, 60))
 	space.collisionPersistence = 3
 
+	space.idleSpeedThreshold = 0
+	space.sleepTimeThreshold = Inf
+
+	space.FixedTimestep = 1.0 / 60.0
+	space.MaxSubsteps = 5
+
 	space.Constraints = make([]Constraint, 0)
 
 	space.Bodies = make([]*Body, 0)
 	space.deleteBodies = make([]*Body, 0)
 	space.sleepingComponents = make([]*Body, 0)
+	space.rousedBodies = make([]*Body, 0)
 
-	space.staticShapes = NewBBTree(nil)
-	space.activeShapes = NewBBTree(space.staticShapes)
+	space.staticShapes = newIndex(nil)
+	space.activeShapes = newIndex(space.staticShapes)
 	space.cachedArbiters = make(map[HashPair]*Arbiter)
 	space.Arbiters = make([]*Arbiter, 0)
+	space.typeHandlers = make(map[collisionTypePair]*CollisionHandler)
+	space.wildcardHandlers = make(map[CollisionType]*CollisionHandler)
 	space.ArbiterBuffer = make([]*Arbiter, ArbiterBufferSize)
 
 	for i := 0; i < len(space.ArbiterBuffer); i++ {
@@ -148,6 +195,7 @@ func (space *Space) Destory() {
 func (space *Space) Destroy() {
 	space.Bodies = nil
 	space.sleepingComponents = nil
+	space.rousedBodies = nil
 	space.staticShapes = nil
 	space.activeShapes = nil
 	space.cachedArbiters = nil
@@ -156,6 +204,83 @@ func (space *Space) Destroy() {
 	space.ContactBuffer = nil
 }
 
+// Sets the speed threshold for a body to be considered idle.
+// The default value of 0 means to let the space guess a good threshold based on gravity.
+func (space *Space) SetIdleSpeedThreshold(threshold float32) {
+	space.idleSpeedThreshold = threshold
+}
+
+func (space *Space) IdleSpeedThreshold() float32 {
+	return space.idleSpeedThreshold
+}
+
+// Sets the time a group of bodies must remain idle in order to fall asleep.
+// The default value of Inf disables sleeping.
+func (space *Space) SetSleepTimeThreshold(threshold float32) {
+	space.sleepTimeThreshold = threshold
+}
+
+func (space *Space) SleepTimeThreshold() float32 {
+	return space.sleepTimeThreshold
+}
+
+// Enables rebuilding the contact graph during each step. Must be enabled to use Body.EachArbiter().
+// The contact graph is always built when sleeping is enabled.
+func (space *Space) SetEnableContactGraph(enable bool) {
+	space.enableContactGraph = enable
+}
+
+func (space *Space) EnableContactGraph() bool {
+	return space.enableContactGraph
+}
+
+func (space *Space) lock() {
+	space.locked++
+}
+
+// Unlocks the space, runPostStep runs the post-step callbacks once it's no longer locked.
+func (space *Space) unlock(runPostStep bool) {
+	space.locked--
+	if space.locked < 0 {
+		panic("Internal Error: Space lock underflow.")
+	}
+
+	if space.locked == 0 {
+		for i, body := range space.rousedBodies {
+			space.ActiveBody(body)
+			space.rousedBodies[i] = nil
+		}
+		space.rousedBodies = space.rousedBodies[0:0]
+
+		if runPostStep {
+			space.runPostStepCallbacks()
+		}
+	}
+}
+
+// Advances the space by elapsed seconds in substeps of FixedTimestep, running at most MaxSubsteps of them.
+// The remainder is carried over to the next call. Returns how far the space is into the next substep
+// as a fraction between 0 and 1, pass it to Body.InterpolatedPosition() and Body.InterpolatedAngle() for rendering.
+func (space *Space) Update(elapsed float32) (alpha float32) {
+	dt := space.FixedTimestep
+	if dt <= 0 {
+		return 0
+	}
+
+	space.accumulator += elapsed
+	for steps := 0; space.accumulator >= dt; steps++ {
+		if space.MaxSubsteps > 0 && steps == space.MaxSubsteps {
+			// Drop the time we can't catch up with.
+			space.accumulator = float32(math.Mod(float64(space.accumulator), float64(dt)))
+			break
+		}
+		space.Step(dt)
+		space.accumulator -= dt
+	}
+
+	return space.accumulator / dt
+}
+
 func (space *Space) Step(dt float32) {
 
 	// don't step if the timestep is 0!
@@ -165,10 +290,13 @@ func (space *Space) Step(dt float32) {
 
 	stepStart := time.Now()
 
-	bodies := space.Bodies
-
 	for _, arb := range space.Arbiters {
 		arb.state = arbiterStateNormal
+
+		// If both bodies are awake, unthread the arbiter from the contact graph.
+		if !arb.BodyA.IsSleeping() && !arb.BodyB.IsSleeping() {
+			arb.unthread()
+		}
 	}
 
 	space.Arbiters = space.Arbiters[0:0]
@@ -178,13 +306,29 @@ func (space *Space) Step(dt float32) {
 
 	space.stamp++
 
-	for _, body := range bodies {
+	// Bodies whose shapes changed get their new mass before they move.
+	for _, body := range space.Bodies {
+		body.updateMass()
+	}
+
+	space.lock()
+
+	for _, body := range space.Bodies {
 		if body.Enabled {
+			body.prevP = body.p
+			body.prevA = body.a
+			body.prevStamp = space.stamp
 			body.UpdatePosition(dt)
 		}
 	}
 
-	for _, body := range bodies {
+	for _, body := range space.Bodies {
+		if body.Enabled && body.Bullet {
+			space.sweepBullet(body)
+		}
+	}
+
+	for _, body := range space.Bodies {
 		if body.Enabled {
 			body.UpdateShapes()
 		}
@@ -196,21 +340,36 @@ func (space *Space) Step(dt float32) {
 	})
 	space.ReindexQueryTime = time.Since(start)
 
+	space.unlock(false)
+
+	// Rebuild the contact graph (and detect sleeping components if sleeping is enabled)
+	if space.enableContactGraph || !math.IsInf(float64(space.sleepTimeThreshold), 1) {
+		space.ProcessComponents(dt)
+	}
+
+	space.lock()
+
 	//axc := space.activeShapes.SpatialIndexClass.(*BBTree)
 	//PrintTree(axc.root)
 
 	for h, arb := range space.cachedArbiters {
+		a, b := arb.BodyA, arb.BodyB
+
+		// Preserve arbiters on sensors and rejected arbiters for sleeping objects.
+		// This prevents errant separate callbacks from happening.
+		if (a.IsStatic() || a.IsSleeping()) && (b.IsStatic() || b.IsSleeping()) {
+			continue
+		}
+
 		ticks := space.stamp - arb.stamp
-		deleted := (arb.BodyA.deleted || arb.BodyB.deleted)
-		disabled := !(arb.BodyA.Enabled || arb.BodyB.Enabled)
-		if (ticks >= 1 && arb.state != arbiterStateCached) || deleted || disabled {
-			arb.state = arbiterStateCached
-			if arb.BodyA.CallbackHandler != nil {
-				arb.BodyA.CallbackHandler.CollisionExit(arb)
-			}
-			if arb.BodyB.CallbackHandler != nil {
-				arb.BodyB.CallbackHandler.CollisionExit(arb)
+		deleted := a.deleted || b.deleted || arb.ShapeA.space != space || arb.ShapeB.space != space
+		disabled := !(a.Enabled || b.Enabled)
+		if arb.state != arbiterStateCached && (ticks >= 1 || deleted || disabled) {
+			if deleted {
+				arb.state = arbiterStateInvalidated
 			}
+			arb.callSeparate(space)
+			arb.state = arbiterStateCached
 		}
 		if ticks > time.Duration(space.collisionPersistence) || deleted {
 			delete(space.cachedArbiters, h)
@@ -237,7 +396,7 @@ func (space *Space) Step(dt float32) {
 	ldamping := float32(math.Pow(float64(space.LinearDamping), float64(dt)))
 	adamping := float32(math.Pow(float64(space.AngularDamping), float64(dt)))
 
-	for _, body := range bodies {
+	for _, body := range space.Bodies {
 		if body.Enabled {
 			if body.IgnoreGravity {
 				body.UpdateVelocity(Vector_Zero, ldamping, adamping, dt)
@@ -291,15 +450,20 @@ func (space *Space) Step(dt float32) {
 		con.PostSolve()
 	}
 
-	for _, arb := range space.Arbiters {
-		if arb.ShapeA.Body.CallbackHandler != nil {
-			arb.ShapeA.Body.CallbackHandler.CollisionPostSolve(arb)
-		}
-		if arb.ShapeB.Body.CallbackHandler != nil {
-			arb.ShapeB.Body.CallbackHandler.CollisionPostSolve(arb)
+	// Removing the constraints now would disturb the iteration, they are removed after unlocking.
+	for _, constraint := range space.Constraints {
+		impulse := FAbs(constraint.Impulse())
+		if constraint.Constraint().breaks(impulse, dt) {
+			space.brokenConstraints = append(space.brokenConstraints, brokenConstraint{constraint, impulse})
 		}
 	}
 
+	for _, arb := range space.Arbiters {
+		arb.callPostSolve(space)
+	}
+
+	space.unlock(false)
+
 	if len(space.deleteBodies) > 0 {
 		for _, body := range space.deleteBodies {
 			space.removeBody(body)
@@ -307,6 +471,21 @@ func (space *Space) Step(dt float32) {
 		space.deleteBodies = space.deleteBodies[0:0]
 	}
 
+	if len(space.brokenConstraints) > 0 {
+		for i, broken := range space.brokenConstraints {
+			con := broken.constraint.Constraint()
+			// The callback runs first so it can still access the bodies, it may also remove the constraint itself.
+			con.broken(broken.constraint, broken.impulse)
+			if con.space == space {
+				space.TryRemoveConstraint(broken.constraint)
+			}
+			space.brokenConstraints[i] = brokenConstraint{}
+		}
+		space.brokenConstraints = space.brokenConstraints[0:0]
+	}
+
+	space.runPostStepCallbacks()
+
 	stepEnd := time.Now()
 	space.StepTime = stepEnd.Sub(stepStart)
 }
@@ -362,6 +541,11 @@ func (space *Space) QueryStatic(obj Indexable, aabb AABB, fnc SpatialIndexQueryF
 }
 
 func (space *Space) SpacePointQueryFirst(point Vect, layers Layer, group Group, checkSensors bool) (shape *Shape) {
+	return space.SpacePointQueryFirstWithFilter(point, layerFilter(layers, group), checkSensors)
+}
+
+// Returns the first shape that passes filter and contains point, or nil.
+func (space *Space) SpacePointQueryFirstWithFilter(point Vect, filter ShapeFilter, checkSensors bool) (shape *Shape) {
 
 	found := false
 	pointFunc := func(a, b Indexable) {
@@ -376,8 +560,8 @@ func (space *Space) SpacePointQueryFirst(point Vect, layers Layer, group Group,
 			}
 			contacts := space.pullContactBuffer()
 			numContacts := collide(contacts, shapeA, shapeB)
+			space.pushContactBuffer(contacts)
 			if numContacts <= 0 {
-				space.pushContactBuffer(contacts)
 				return
 			}
 			shape = shapeB
@@ -387,8 +571,7 @@ func (space *Space) SpacePointQueryFirst(point Vect, layers Layer, group Group,
 
 	dot := NewCircle(Vector_Zero, 0.5)
 	dot.BB = dot.update(NewTransform(point, 0))
-	dot.Layer = layers
-	dot.Group = group
+	dot.Filter = filter
 	space.staticShapes.Query(dot, dot.AABB(), pointFunc)
 	if found {
 		return
@@ -399,6 +582,11 @@ func (space *Space) SpacePointQueryFirst(point Vect, layers Layer, group Group,
 }
 
 func (space *Space) SpacePointQuery(point Vect, layers Layer, group Group, checkSensors bool) (shapes []*Shape) {
+	return space.SpacePointQueryWithFilter(point, layerFilter(layers, group), checkSensors)
+}
+
+// Returns every shape that passes filter and contains point.
+func (space *Space) SpacePointQueryWithFilter(point Vect, filter ShapeFilter, checkSensors bool) (shapes []*Shape) {
 
 	pointFunc := func(a, b Indexable) {
 		shapeB := b.Shape()
@@ -409,8 +597,8 @@ func (space *Space) SpacePointQuery(point Vect, layers Layer, group Group, check
 			}
 			contacts := space.pullContactBuffer()
 			numContacts := collide(contacts, shapeA, shapeB)
+			space.pushContactBuffer(contacts)
 			if numContacts <= 0 {
-				space.pushContactBuffer(contacts)
 				return
 			}
 			shapes = append(shapes, shapeB)
@@ -419,14 +607,121 @@ func (space *Space) SpacePointQuery(point Vect, layers Layer, group Group, check
 
 	dot := NewCircle(Vector_Zero, 0.5)
 	dot.BB = dot.update(NewTransform(point, 0))
-	dot.Layer = layers
-	dot.Group = group
+	dot.Filter = filter
 	space.staticShapes.Query(dot, dot.AABB(), pointFunc)
 	space.activeShapes.Query(dot, dot.AABB(), pointFunc)
 
 	return
 }
 
+// Calls fnc for every shape overlapping shape, with the contact points between them.
+// The normals of the contacts point from shape to the other shape.
+// The shapes are filtered with the filter, group and layers of shape, sensors are reported but are not counted as collisions.
+// shape doesn't need to be added to the space. If it has a body it is moved to the body's position first.
+// Returns true if shape overlaps any shape and neither is a sensor.
+func (space *Space) ShapeQuery(shape *Shape, fnc func(other *Shape, points *ContactPointSet)) bool {
+	if shape.Body != nil {
+		shape.Update()
+	}
+
+	anyCollision := false
+	queryFunc := func(_, b Indexable) {
+		other := b.Shape()
+		if queryRejectShapes(shape, other) {
+			return
+		}
+
+		// collide() needs the shapes ordered by type.
+		var set ContactPointSet
+		contacts := space.pullContactBuffer()
+		if shape.ShapeType() <= other.ShapeType() {
+			set.Count = collide(contacts, shape, other)
+		} else {
+			set.Count = collide(contacts, other, shape)
+			for _, con := range contacts[:set.Count] {
+				con.n = Mult(con.n, -1)
+			}
+		}
+		for i, con := range contacts[:set.Count] {
+			set.Points[i] = ContactPoint{Point: con.p, Normal: con.n, Dist: con.dist}
+		}
+		space.pushContactBuffer(contacts)
+
+		if set.Count > 0 {
+			anyCollision = anyCollision || !(shape.IsSensor || other.IsSensor)
+			if fnc != nil {
+				fnc(other, &set)
+			}
+		}
+	}
+
+	space.lock()
+	space.activeShapes.Query(shape, shape.AABB(), queryFunc)
+	space.staticShapes.Query(shape, shape.AABB(), queryFunc)
+	space.unlock(true)
+
+	return anyCollision
+}
+
+func segmentQueryReject(shape *Shape, filter ShapeFilter, checkSensors bool) bool {
+	return shape.filter().Reject(filter) ||
+		(!checkSensors && shape.IsSensor) || (shape.Body != nil && !shape.Body.Enabled)
+}
+
+// Calls fnc for every shape hit by the segment from start to end, in no particular order.
+func (space *Space) SegmentQuery(start, end Vect, layers Layer, group Group, checkSensors bool, fnc func(info *SegmentQueryInfo)) {
+	space.SegmentQueryWithFilter(start, end, layerFilter(layers, group), checkSensors, fnc)
+}
+
+// Calls fnc for every shape that passes filter and is hit by the segment from start to end, in no particular order.
+func (space *Space) SegmentQueryWithFilter(start, end Vect, filter ShapeFilter, checkSensors bool, fnc func(info *SegmentQueryInfo)) {
+	queryFunc := func(_, b Indexable) float32 {
+		shape := b.Shape()
+		var info SegmentQueryInfo
+		if !segmentQueryReject(shape, filter, checkSensors) && shape.segmentQuery(start, end, 0, &info) {
+			fnc(&info)
+		}
+		return 1
+	}
+
+	space.lock()
+	space.staticShapes.SegmentQuery(nil, start, end, 1, queryFunc)
+	space.activeShapes.SegmentQuery(nil, start, end, 1, queryFunc)
+	space.unlock(true)
+}
+
+// Returns the first shape hit by the segment from start to end, or nil if nothing was hit.
+func (space *Space) SegmentQueryFirst(start, end Vect, layers Layer, group Group, checkSensors bool) *SegmentQueryInfo {
+	return space.SegmentQueryFirstWithFilter(start, end, layerFilter(layers, group), checkSensors)
+}
+
+// Returns the first shape that passes filter and is hit by the segment from start to end, or nil if nothing was hit.
+func (space *Space) SegmentQueryFirstWithFilter(start, end Vect, filter ShapeFilter, checkSensors bool) *SegmentQueryInfo {
+	var first SegmentQueryInfo
+	first.Alpha = 1
+
+	queryFunc := func(_, b Indexable) float32 {
+		shape := b.Shape()
+		var info SegmentQueryInfo
+		if !segmentQueryReject(shape, filter, checkSensors) &&
+			shape.segmentQuery(start, end, 0, &info) &&
+			(first.Shape == nil || info.Alpha < first.Alpha) {
+			first = info
+		}
+		return first.Alpha
+	}
+
+	space.lock()
+	space.staticShapes.SegmentQuery(nil, start, end, 1, queryFunc)
+	space.activeShapes.SegmentQuery(nil, start, end, first.Alpha, queryFunc)
+	space.unlock(true)
+
+	if first.Shape == nil {
+		return nil
+	}
+	return &first
+}
+
 /*
 func (space *Space) SpacePointQuery(point Vect, layers Layer, group Group, cpSpacePointQueryFunc func, void *data)
 {
@@ -439,135 +734,232 @@ func (space *Space) SpacePointQuery(point Vect, layers Layer, group Group, cpSpa
 	} cpSpaceUnlock(space, cpTrue);
 }
 */
+// Adds a sleeping body back to the simulation.
+// When called while the space is locked, the body is activated once the space unlocks.
 func (space *Space) ActiveBody(body *Body) error {
 	if body.IsRogue() {
 		return errors.New("Internal error: Attempting to activate a rouge body.")
 	}
 
+	if space.locked > 0 {
+		// ActiveBody() is called again once the space is unlocked
+		for _, roused := range space.rousedBodies {
+			if roused == body {
+				return nil
+			}
+		}
+		space.rousedBodies = append(space.rousedBodies, body)
+		return nil
+	}
+
 	space.Bodies = append(space.Bodies, body)
 
 	for _, shape := range body.Shapes {
-		space.staticShapes.Remove(shape)
-		space.activeShapes.Insert(shape)
+		if shape.space == space {
+			space.staticShapes.Remove(shape)
+			space.activeShapes.Insert(shape)
+		}
 	}
-	/*
-		for _, arb := range body.Arbiters {
-			bodyA := arb.BodyA
-			if body == bodyA || bodyA.IsStatic() {
 
-					int numContacts = arb->numContacts;
-					cpContact *contacts = arb->contacts;
+	for edge := body.arbiterList; edge != nil; edge = edge.Next {
+		arb := edge.Arbiter
+		bodyA := arb.BodyA
+
+		// Arbiters are shared between two bodies that are always woken up together.
+		// You only want to restore the arbiter once, so bodyA is arbitrarily chosen to own the arbiter.
+		// The edge case is when static bodies are involved as the static bodies never actually sleep.
+		// If the static body is bodyB then all is good. If the static body is bodyA, that can easily be checked.
+		if body == bodyA || bodyA.IsStatic() {
+			// Reinsert the arbiter into the arbiter cache
+			space.cachedArbiters[newPair(arb.ShapeA, arb.ShapeB)] = arb
+
+			// Update the arbiter's state
+			arb.stamp = space.stamp
+			arb.handler, arb.swapped = space.lookupHandler(arb.ShapeA.CollisionType, arb.ShapeB.CollisionType)
+			space.Arbiters = append(space.Arbiters, arb)
+		}
+	}
 
-					// Restore contact values back to the space's contact buffer memory
-					arb->contacts = cpContactBufferGetArray(space);
-					memcpy(arb->contacts, contacts, numContacts*sizeof(cpContact));
-					cpSpacePushContacts(space, numContacts);
+	for constraint := body.constraintList; constraint != nil; {
+		con := constraint.Constraint()
+		if body == con.BodyA || con.BodyA.IsStatic() {
+			space.Constraints = append(space.Constraints, constraint)
+		}
+		constraint = con.next(body)
+	}
 
-					// Reinsert the arbiter into the arbiter cache
-					arbHashID := hashPair(arb.BodyA.Hash()*20, arb.BodyB.Hash()*10)
-					space.cachedArbiters[arbHashID] = arb
+	return nil
+}
 
-					// Update the arbiter's state
-					arb.stamp = space.stamp
-					space->arbiters = append(space->arbiters, arb)
+// Removes the body and its shapes from the simulation and moves its shapes to the static index.
+func (space *Space) deactivateBody(body *Body) {
+	space.Bodies = deleteBody(space.Bodies, body)
 
-					//cpfree(contacts);
+	for _, shape := range body.Shapes {
+		if shape.space == space {
+			space.activeShapes.Remove(shape)
+			space.staticShapes.Insert(shape)
+		}
+	}
 
-			}
+	for edge := body.arbiterList; edge != nil; edge = edge.Next {
+		arb := edge.Arbiter
+		bodyA := arb.BodyA
+		if body == bodyA || bodyA.IsStatic() {
+			// The contacts stay with the sleeping arbiter, they are not returned to the buffer.
+			space.uncacheArbiter(arb)
 		}
-	*/
+	}
 
-	return nil
+	for constraint := body.constraintList; constraint != nil; {
+		con := constraint.Constraint()
+		if body == con.BodyA || con.BodyA.IsStatic() {
+			space.Constraints = deleteConstraint(space.Constraints, constraint)
+		}
+		constraint = con.next(body)
+	}
+}
+
+func (space *Space) uncacheArbiter(arb *Arbiter) {
+	delete(space.cachedArbiters, newPair(arb.ShapeA, arb.ShapeB))
+
+	for i, a := range space.Arbiters {
+		if a == arb {
+			last := len(space.Arbiters) - 1
+			space.Arbiters[i] = space.Arbiters[last]
+			space.Arbiters[last] = nil
+			space.Arbiters = space.Arbiters[:last]
+			break
+		}
+	}
+}
+
+func floodFillComponent(root, body *Body) {
+	// Rogue and kinematic bodies cannot be put to sleep and prevent bodies they are touching from sleeping anyway.
+	// Static bodies are effectively sleeping all the time.
+	// Removed bodies are still in the contact graph until the end of the step but they aren't in space.Bodies,
+	// so their component nodes would never be reset.
+	if body.IsRogue() || body.IsStatic() || body.IsKinematic() || body.deleted {
+		return
+	}
+
+	otherRoot := body.ComponentRoot()
+	if otherRoot == nil {
+		root.componentAdd(body)
+		for edge := body.arbiterList; edge != nil; edge = edge.Next {
+			floodFillComponent(root, edge.Other)
+		}
+		for constraint := body.constraintList; constraint != nil; {
+			con := constraint.Constraint()
+			if body == con.BodyA {
+				floodFillComponent(root, con.BodyB)
+			} else {
+				floodFillComponent(root, con.BodyA)
+			}
+			constraint = con.next(body)
+		}
+	} else if otherRoot != root {
+		log.Printf("Internal Error: Inconsistency detected in the contact graph.")
+	}
 }
 
+// Rebuilds the contact graph and puts idle components to sleep if sleeping is enabled.
 func (space *Space) ProcessComponents(dt float32) {
 
-	sleep := math.IsInf(float64(space.sleepTimeThreshold), 0)
-	bodies := space.Bodies
-	_ = bodies
+	sleep := !math.IsInf(float64(space.sleepTimeThreshold), 1)
+
+	// Calculate the kinetic energy of all the bodies.
 	if sleep {
 		dv := space.idleSpeedThreshold
 		dvsq := float32(0)
-		if dv == 0 {
+		if dv != 0 {
 			dvsq = dv * dv
 		} else {
 			dvsq = space.Gravity.LengthSqr() * dt * dt
 		}
 
+		// update idling and reset component nodes
 		for _, body := range space.Bodies {
+			// Kinematic bodies never fall asleep.
+			if body.IsKinematic() {
+				continue
+			}
+			// Need to deal with infinite mass objects
 			keThreshold := float32(0)
 			if dvsq != 0 {
 				keThreshold = body.m * dvsq
 			}
-			body.node.IdleTime = 0
-			if body.KineticEnergy() <= keThreshold {
+			if body.KineticEnergy() > keThreshold {
+				body.node.IdleTime = 0
+			} else {
 				body.node.IdleTime += dt
 			}
 		}
 	}
 
-	for _, arb := range space.Arbiters {
+	// Awaken any sleeping bodies found and then push arbiters to the bodies' lists.
+	for i, count := 0, len(space.Arbiters); i < count; i++ {
+		arb := space.Arbiters[i]
 		a, b := arb.BodyA, arb.BodyB
-		_, _ = a, b
-		if sleep {
 
-		}
-	}
-	/*
-		// Awaken any sleeping bodies found and then push arbiters to the bodies' lists.
-		cpArray *arbiters = space->arbiters;
-		for(int i=0, count=arbiters->num; i<count; i++){
-			cpArbiter *arb = (cpArbiter*)arbiters->arr[i];
-			cpBody *a = arb->body_a, *b = arb->body_b;
-
-			if(sleep){
-				if((cpBodyIsRogue(b) && !cpBodyIsStatic(b)) || cpBodyIsSleeping(a)) cpBodyActivate(a);
-				if((cpBodyIsRogue(a) && !cpBodyIsStatic(a)) || cpBodyIsSleeping(b)) cpBodyActivate(b);
+		if sleep {
+			if b.IsKinematic() || (b.IsRogue() && !b.IsStatic()) || a.IsSleeping() {
+				a.BodyActivate()
+			}
+			if a.IsKinematic() || (a.IsRogue() && !a.IsStatic()) || b.IsSleeping() {
+				b.BodyActivate()
 			}
-
-			cpBodyPushArbiter(a, arb);
-			cpBodyPushArbiter(b, arb);
 		}
 
-		if(sleep){
-			// Bodies should be held active if connected by a joint to a non-static rouge body.
-			cpArray *constraints = space->constraints;
-			for(int i=0; i<constraints->num; i++){
-				cpConstraint *constraint = (cpConstraint *)constraints->arr[i];
-				cpBody *a = constraint->a, *b = constraint->b;
+		a.pushArbiter(arb)
+		b.pushArbiter(arb)
+	}
 
-				if(cpBodyIsRogue(b) && !cpBodyIsStatic(b)) cpBodyActivate(a);
-				if(cpBodyIsRogue(a) && !cpBodyIsStatic(a)) cpBodyActivate(b);
-			}
+	if !sleep {
+		return
+	}
 
-			// Generate components and deactivate sleeping ones
-			for(int i=0; i<bodies->num;){
-				cpBody *body = (cpBody*)bodies->arr[i];
+	// Bodies should be held active if connected by a joint to a kinematic or non-static rouge body.
+	for _, constraint := range space.Constraints {
+		con := constraint.Constraint()
+		a, b := con.BodyA, con.BodyB
 
-				if(ComponentRoot(body) == NULL){
-					// Body not in a component yet. Perform a DFS to flood fill mark
-					// the component in the contact graph using this body as the root.
-					FloodFillComponent(body, body);
+		if b.IsKinematic() || (b.IsRogue() && !b.IsStatic()) {
+			a.BodyActivate()
+		}
+		if a.IsKinematic() || (a.IsRogue() && !a.IsStatic()) {
+			b.BodyActivate()
+		}
+	}
 
-					// Check if the component should be put to sleep.
-					if(!ComponentActive(body, space->sleepTimeThreshold)){
-						cpArrayPush(space->sleepingComponents, body);
-						CP_BODY_FOREACH_COMPONENT(body, other) cpSpaceDeactivateBody(space, other);
+	// Generate components and deactivate sleeping ones
+	for i := 0; i < len(space.Bodies); {
+		body := space.Bodies[i]
 
-						// cpSpaceDeactivateBody() removed the current body from the list.
-						// Skip incrementing the index counter.
-						continue;
-					}
-				}
+		if body.ComponentRoot() == nil {
+			// Body not in a component yet. Perform a DFS to flood fill mark
+			// the component in the contact graph using this body as the root.
+			floodFillComponent(body, body)
 
-				i++;
+			// Check if the component should be put to sleep.
+			if !body.componentIsActive(space.sleepTimeThreshold) {
+				space.sleepingComponents = append(space.sleepingComponents, body)
+				for other := body; other != nil; other = other.node.Next {
+					space.deactivateBody(other)
+				}
 
-				// Only sleeping bodies retain their component node pointers.
-				body->node.root = NULL;
-				body->node.next = NULL;
+				// deactivateBody() removed the current body from the list.
+				// Skip incrementing the index counter.
+				continue
 			}
 		}
-	*/
+
+		i++
+
+		// Only sleeping bodies retain their component node pointers.
+		body.node.Root = nil
+		body.node.Next = nil
+	}
 }
 
 // Creates an arbiter between the given shapes.
@@ -598,6 +990,8 @@ func (space *Space) CreateArbiter(sa, sb *Shape) *Arbiter {
 
 	arb.Surface_vr = Vect{}
 	arb.stamp = 0
+	arb.handler = nil
+	arb.swapped = false
 	//arb.nodeA = new(ArbiterEdge)
 	//arb.nodeB = new(ArbiterEdge)
 	arb.state = arbiterStateFirstColl
@@ -617,12 +1011,15 @@ func SpaceCollideShapes(a, b *Shape, space *Space) {
 	if queryReject(a, b) {
 		return
 	}
+	if space.ShouldCollide != nil && !space.ShouldCollide(a, b) {
+		return
+	}
 
 	if a.ShapeType() > b.ShapeType() {
 		a, b = b, a
 	}
 
-	//cpCollisionHandler *handler = cpSpaceLookupHandler(space, a->collision_type, b->collision_type);
+	handler, swapped := space.lookupHandler(a.CollisionType, b.CollisionType)
 
 	sensor := a.IsSensor || b.IsSensor
 	//if(sensor && handler == &cpDefaultCollisionHandler) return;
@@ -658,6 +1055,7 @@ func SpaceCollideShapes(a, b *Shape, space *Space) {
 	if arb.Contacts != nil {
 		oldContacts = arb.Contacts
 	}
+	arb.handler, arb.swapped = handler, swapped
 	arb.update(a, b, contacts, numContacts)
 	if oldContacts != nil {
 		space.pushContactBuffer(oldContacts)
@@ -666,32 +1064,16 @@ func SpaceCollideShapes(a, b *Shape, space *Space) {
 	space.cachedArbiters[arbHashID] = arb
 
 	// Call the begin function first if it's the first step
-	if arb.state == arbiterStateFirstColl {
-		ignore := false
-		if b.Body.CallbackHandler != nil {
-			ignore = !b.Body.CallbackHandler.CollisionEnter(arb)
-		}
-		if a.Body.CallbackHandler != nil {
-			ignore = ignore || !a.Body.CallbackHandler.CollisionEnter(arb)
-		}
-		if ignore {
-			arb.Ignore() // permanently ignore the collision until separation
-		}
+	if arb.state == arbiterStateFirstColl && !arb.callBegin(space) {
+		arb.Ignore() // permanently ignore the collision until separation
 	}
 
-	preSolveResult := true
+	preSolveResult := false
 
 	// Ignore the arbiter if it has been flagged
 	if arb.state != arbiterStateIgnore {
 		// Call preSolve
-		if arb.ShapeA.Body.CallbackHandler != nil {
-			preSolveResult = arb.ShapeA.Body.CallbackHandler.CollisionPreSolve(arb)
-		}
-		if arb.ShapeB.Body.CallbackHandler != nil {
-			preSolveResult = preSolveResult || arb.ShapeB.Body.CallbackHandler.CollisionPreSolve(arb)
-		}
-	} else {
-		preSolveResult = false
+		preSolveResult = arb.callPreSolve(space)
 	}
 
 	if preSolveResult &&
@@ -718,12 +1100,14 @@ func SpaceCollideShapes(a, b *Shape, space *Space) {
 }
 
 func queryRejectShapes(a, b *Shape) bool {
-	return a == b || (a.Group != 0 && a.Group == b.Group) || (a.Layer&b.Layer) == 0 || (a.Body != nil && !a.Body.Enabled) || (b.Body != nil && !b.Body.Enabled)
+	return a == b || a.filter().Reject(b.filter()) || (a.Body != nil && !a.Body.Enabled) || (b.Body != nil && !b.Body.Enabled)
 }
 
 func queryReject(a, b *Shape) bool {
-	//|| (a.Layer & b.Layer) != 0
-	return a.Body == b.Body || (a.Group != 0 && a.Group == b.Group) || (a.Layer&b.Layer) == 0 || !a.Body.Enabled || !b.Body.Enabled || (math.IsInf(float64(a.Body.m), 0) && math.IsInf(float64(b.Body.m), 0)) || !TestOverlapPtr(&a.BB, &b.BB)
+	return a.Body == b.Body || a.filter().Reject(b.filter()) || !a.Body.Enabled || !b.Body.Enabled ||
+		// Static and kinematic bodies only collide with dynamic bodies.
+		(a.Body.Type() != BodyType_Dynamic && b.Body.Type() != BodyType_Dynamic) ||
+		(math.IsInf(float64(a.Body.m), 0) && math.IsInf(float64(b.Body.m), 0)) || !TestOverlapPtr(&a.BB, &b.BB)
 }
 
 type RayCast struct {
@@ -732,8 +1116,14 @@ type RayCast struct {
 }
 
 type RayCastHit struct {
-	Body *Body
+	Body  *Body
+	Shape *Shape
+	// Fraction of the direction at which the ray hit the shape.
 	MinT float32
+	// The point where the ray hit the shape.
+	Point Vect
+	// The normal of the surface that was hit.
+	Normal Vect
 }
 
 const EPS = 0.00001
@@ -794,110 +1184,232 @@ func RayAgainstCircle(cast *RayCast, circle *CircleShape, outT *float32) bool {
 	return false
 }
 
+// Returns every shape hit by the ray from begin to begin+direction in both the static and the active index.
+// The hits are sorted by their distance from begin. Sensors are skipped, use SegmentQuery() to find them.
 func (space *Space) RayCastAll(begin Vect, direction Vect) []*RayCastHit {
 	hits := []*RayCastHit{}
 
-	rayCast := &RayCast{
-		begin: begin,
-		dir:   direction,
-	}
-
 	end := begin
 	end.Add(direction)
 
-	var l, b, r, t float32
-	if begin.X > end.X {
-		l = end.X
-		r = begin.X
-	} else {
-		r = end.X
-		l = begin.X
-	}
-
-	if begin.Y > end.Y {
-		t = begin.Y
-		b = end.Y
-	} else {
-		b = begin.Y
-		t = end.Y
-	}
+	space.SegmentQueryWithFilter(begin, end, ShapeFilterAll, false, func(info *SegmentQueryInfo) {
+		hits = append(hits, &RayCastHit{
+			Body:   info.Shape.Body,
+			Shape:  info.Shape,
+			MinT:   info.Alpha,
+			Point:  info.Point,
+			Normal: info.Normal,
+		})
+	})
 
-	//first is nill
-	queryFunc := func(_, b Indexable) {
-		shape := b.Shape()
-		body := shape.Body
-
-		shapeType := shape.ShapeType()
-		if shapeType == ShapeType_Polygon {
-			polygon := shape.GetAsPolygon()
-			var t float32 = 0.0
-			if RayAgainstPolygon(rayCast, polygon, &t) {
-				hit := RayCastHit{
-					Body: body,
-					MinT: t,
-				}
-				hits = append(hits, &hit)
-			}
-		} else if shapeType == ShapeType_Circle {
-			circle := shape.GetAsCircle()
-			var t float32 = 0.0
-			if RayAgainstCircle(rayCast, circle, &t) {
-				hit := RayCastHit{
-					Body: body,
-					MinT: t,
-				}
-				hits = append(hits, &hit)
-			} else {
-			}
-		}
-	}
+	sort.Slice(hits, func(i, j int) bool {
+		return hits[i].MinT < hits[j].MinT
+	})
 
-	aabb := NewAABB(l, b, r, t)
-	space.activeShapes.Query(nil, aabb, queryFunc)
 	return hits
 }
 
+// Adds the body and its shapes to the space and returns the body.
+// Does nothing if the body can't be added, TryAddBody() returns the error instead.
 func (space *Space) AddBody(body *Body) *Body {
+	space.TryAddBody(body)
+	return body
+}
+
+// Adds the body and its shapes to the space.
+// Returns ErrNilObject, ErrAlreadyInSpace, ErrSpaceLocked, or ErrInvalidMass, ErrInvalidMoment
+// and ErrInvalidPosition if the body can't be simulated. Nothing is added if one of its shapes
+// is nil or already in another space.
+func (space *Space) TryAddBody(body *Body) error {
+	if body == nil {
+		return ErrNilObject
+	}
 	if body.space != nil {
-		println("This body is already added to a space and cannot be added to another.")
-		return body
+		return ErrAlreadyInSpace
+	}
+	if space.locked != 0 {
+		return ErrSpaceLocked
+	}
+	if err := body.validate(); err != nil {
+		return err
+	}
+	for _, shape := range body.Shapes {
+		if shape == nil {
+			return ErrNilObject
+		}
+		// Shapes removed from a space stay in body.Shapes without a body.
+		if shape.Body == body && shape.space != nil && shape.space != space {
+			return ErrAlreadyInSpace
+		}
 	}
 
 	body.space = space
+	body.deleted = false
 	if !body.IsStatic() {
 		space.Bodies = append(space.Bodies, body)
 	}
 
 	for _, shape := range body.Shapes {
-		if shape.space == nil {
-			space.AddShape(shape)
+		if shape.Body == body && shape.space == nil {
+			if err := space.TryAddShape(shape); err != nil {
+				return err
+			}
 		}
 	}
 
-	return body
+	return nil
 }
 
+// Adds the shape to the space and returns it.
+// Does nothing if the shape can't be added, TryAddShape() returns the error instead.
 func (space *Space) AddShape(shape *Shape) *Shape {
+	space.TryAddShape(shape)
+	return shape
+}
+
+// Adds the shape to the space. Returns ErrNilObject, ErrNoBody, ErrAlreadyInSpace or ErrSpaceLocked.
+func (space *Space) TryAddShape(shape *Shape) error {
+	if shape == nil {
+		return ErrNilObject
+	}
+	if shape.Body == nil {
+		return ErrNoBody
+	}
 	if shape.space != nil {
-		println("This shape is already added to a space and cannot be added to another.")
-		return shape
+		return ErrAlreadyInSpace
+	}
+	if space.locked != 0 {
+		return ErrSpaceLocked
 	}
 
+	body := shape.Body
+	body.BodyActivate()
+
 	shape.space = space
 	shape.Update()
-	if shape.Body.IsStatic() {
+	if body.IsStatic() {
 		space.staticShapes.Insert(shape)
 	} else {
 		space.activeShapes.Insert(shape)
 	}
 
-	return shape
+	return nil
+}
+
+// Adds many shapes at once, like loading the static geometry of a level.
+// The shapes are inserted into the indexes in bulk, which builds better balanced trees
+// than adding them one by one. Nothing is added if any of the shapes can't be added.
+func (space *Space) AddShapes(shapes ...*Shape) error {
+	if space.locked != 0 {
+		return ErrSpaceLocked
+	}
+
+	seen := make(map[*Shape]bool, len(shapes))
+	for _, shape := range shapes {
+		if shape == nil {
+			return ErrNilObject
+		}
+		if shape.Body == nil {
+			return ErrNoBody
+		}
+		if shape.space != nil || seen[shape] {
+			return ErrAlreadyInSpace
+		}
+		seen[shape] = true
+	}
+
+	var static, active []Indexable
+	for _, shape := range shapes {
+		shape.Body.BodyActivate()
+
+		shape.space = space
+		shape.Update()
+		if shape.Body.IsStatic() {
+			static = append(static, shape)
+		} else {
+			active = append(active, shape)
+		}
+	}
+
+	insertBatch(space.staticShapes, static)
+	insertBatch(space.activeShapes, active)
+	return nil
+}
+
+// Updates the static shapes and their place in the static index.
+// Call it after moving static bodies.
+func (space *Space) ReindexStatic() error {
+	if space.locked != 0 {
+		return ErrSpaceLocked
+	}
+
+	space.staticShapes.Each(func(node *Node) {
+		node.obj.Shape().Update()
+	})
+	space.staticShapes.Reindex()
+	return nil
+}
+
+// Updates the shape and its place in the index, for example after moving its body by hand.
+func (space *Space) ReindexShape(shape *Shape) error {
+	if shape == nil {
+		return ErrNilObject
+	}
+	if shape.space != space {
+		return ErrNotInSpace
+	}
+	if space.locked != 0 {
+		return ErrSpaceLocked
+	}
+
+	shape.Update()
+	// The shape is in one of the indexes.
+	space.activeShapes.ReindexObject(shape)
+	space.staticShapes.ReindexObject(shape)
+	return nil
 }
 
+// Updates all shapes of the body and their place in the index.
+func (space *Space) ReindexShapesForBody(body *Body) error {
+	if body == nil {
+		return ErrNilObject
+	}
+	if body.space != space {
+		return ErrNotInSpace
+	}
+	if space.locked != 0 {
+		return ErrSpaceLocked
+	}
+
+	for _, shape := range body.Shapes {
+		if shape.space == space {
+			space.ReindexShape(shape)
+		}
+	}
+	return nil
+}
+
+// Adds the constraint to the space and returns it.
+// Does nothing if the constraint can't be added, TryAddConstraint() returns the error instead.
 func (space *Space) AddConstraint(constraint Constraint) Constraint {
+	space.TryAddConstraint(constraint)
+	return constraint
+}
+
+// Adds the constraint to the space. Returns ErrNilObject, ErrNoBody, ErrAlreadyInSpace or ErrSpaceLocked.
+func (space *Space) TryAddConstraint(constraint Constraint) error {
+	if constraint == nil {
+		return ErrNilObject
+	}
 	con := constraint.Constraint()
+	if con.BodyA == nil || con.BodyB == nil {
+		return ErrNoBody
+	}
 	if con.space != nil {
-		panic("This shape is already added to a space and cannot be added to another.")
+		return ErrAlreadyInSpace
+	}
+	if space.locked != 0 {
+		return ErrSpaceLocked
 	}
 
 	con.BodyA.BodyActivate()
@@ -905,40 +1417,55 @@ func (space *Space) AddConstraint(constraint Constraint) Constraint {
 	space.Constraints = append(space.Constraints, constraint)
 
 	// Push onto the heads of the bodies' constraint lists
-	//cpBody *a = constraint->a, *b = constraint->b;
-	//constraint->next_a = a->constraintList; a->constraintList = constraint;
-	//constraint->next_b = b->constraintList; b->constraintList = constraint;
+	con.BodyA.pushConstraint(constraint)
+	con.BodyB.pushConstraint(constraint)
 	con.space = space
 
-	return constraint
+	return nil
 }
 
+// Removes the constraint from the space.
+// Does nothing if the constraint can't be removed, TryRemoveConstraint() returns the error instead.
 func (space *Space) RemoveConstraint(constraint Constraint) {
+	space.TryRemoveConstraint(constraint)
+}
+
+// Removes the constraint from the space. Returns ErrNilObject, ErrNotInSpace or ErrSpaceLocked.
+func (space *Space) TryRemoveConstraint(constraint Constraint) error {
+	if constraint == nil {
+		return ErrNilObject
+	}
 	con := constraint.Constraint()
-	if con.space == nil {
-		panic("Cannot remove a constraint that was not added to the space. (Removed twice maybe?)")
+	if con.space != space {
+		return ErrNotInSpace
+	}
+	if space.locked != 0 {
+		return ErrSpaceLocked
 	}
 
 	con.BodyA.BodyActivate()
 	con.BodyB.BodyActivate()
 
-	for i, c := range space.Constraints {
-		if constraint == c {
-			space.Constraints[i], space.Constraints = space.Constraints[len(space.Constraints)-1], space.Constraints[:len(space.Constraints)-1]
-			break
-		}
-	}
+	space.Constraints = deleteConstraint(space.Constraints, constraint)
 
-	//cpBodyRemoveConstraint(constraint->a, constraint);
-	//cpBodyRemoveConstraint(constraint->b, constraint);
+	con.BodyA.removeConstraint(constraint)
+	con.BodyB.removeConstraint(constraint)
 	con.space = nil
 	con.BodyA = nil
 	con.BodyB = nil
+
+	return nil
 }
 
 func (space *Space) removeBody(body *Body) {
+	// The body may have been woken up after RemoveBody() was called.
+	space.Bodies = deleteBody(space.Bodies, body)
+
 	for _, shape := range body.Shapes {
-		space.RemoveShape(shape)
+		// Shapes removed before the body stay in body.Shapes.
+		if shape.space == space {
+			space.RemoveShape(shape)
+		}
 	}
 	body.space = nil
 	body.Shapes = nil
@@ -948,31 +1475,70 @@ func (space *Space) removeBody(body *Body) {
 	body.UpdatePositionFunc = nil
 }
 
+// Removes the body and its shapes from the space.
+// The removal is deferred to the end of the step so it's safe to call it from callbacks.
+// Does nothing if the body isn't in the space, TryRemoveBody() returns an error instead.
 func (space *Space) RemoveBody(body *Body) {
+	space.TryRemoveBody(body)
+}
+
+// Removes the body and its shapes from the space like RemoveBody().
+// Returns ErrNilObject or ErrNotInSpace.
+func (space *Space) TryRemoveBody(body *Body) error {
 	if body == nil {
-		return
+		return ErrNilObject
 	}
-	body.BodyActivate()
-	for i, pbody := range space.Bodies {
-		if pbody == body {
-			space.Bodies[i], space.Bodies = space.Bodies[len(space.Bodies)-1], space.Bodies[:len(space.Bodies)-1]
-			break
-		}
+	if body.space != space || body.deleted {
+		return ErrNotInSpace
 	}
+	body.BodyActivate()
+	space.Bodies = deleteBody(space.Bodies, body)
 	body.deleted = true
 	space.deleteBodies = append(space.deleteBodies, body)
+
+	return nil
 }
 
+// Removes the shape from the space. The shape stays in the Shapes of its body.
+// Does nothing if the shape can't be removed, TryRemoveShape() returns the error instead.
 func (space *Space) RemoveShape(shape *Shape) {
+	space.TryRemoveShape(shape)
+}
+
+// Removes the shape from the space like RemoveShape(). Returns ErrNilObject, ErrNotInSpace or ErrSpaceLocked.
+func (space *Space) TryRemoveShape(shape *Shape) error {
+	if shape == nil {
+		return ErrNilObject
+	}
+	if shape.space != space {
+		return ErrNotInSpace
+	}
+	if space.locked != 0 {
+		return ErrSpaceLocked
+	}
+
+	body := shape.Body
 	shape.space = nil
-	if shape.Body.IsStatic() {
+	if shape.Mass() > 0 {
+		body.massDirty = true
+	}
+	if body.IsStatic() {
+		body.ActivateStatic(shape)
 		space.staticShapes.Remove(shape)
 	} else {
-		space.activeShapes.Remove(shape)
+		body.BodyActivate()
+		if body.IsSleeping() {
+			// The activation was deferred, the shape is still in the static index.
+			space.staticShapes.Remove(shape)
+		} else {
+			space.activeShapes.Remove(shape)
+		}
 	}
 	shape.Body = nil
 	shape.UserData = nil
 	shape.ShapeClass = nil
+
+	return nil
 }
 
 func (space *Space) pullContactBuffer() (contacts []*Contact) {
@@ -995,3 +1561,35 @@ func (space *Space) pullContactBuffer() (contacts []*Contact) {
 func (space *Space) pushContactBuffer(contacts []*Contact) {
 	space.ContactBuffer = append(space.ContactBuffer, contacts)
 }
+
+// Removes body from bodies by swapping it with the last element.
+func deleteBody(bodies []*Body, body *Body) []*Body {
+	for i, b := range bodies {
+		if b == body {
+			last := len(bodies) - 1
+			bodies[i] = bodies[last]
+			bodies[last] = nil
+			return bodies[:last]
+		}
+	}
+	return bodies
+}
+
+// A constraint that exceeded its break threshold and the impulse it applied.
+type brokenConstraint struct {
+	constraint Constraint
+	impulse    float32
+}
+
+// Removes constraint from constraints by swapping it with the last element.
+func deleteConstraint(constraints []Constraint, constraint Constraint) []Constraint {
+	for i, c := range constraints {
+		if c == constraint {
+			last := len(constraints) - 1
+			constraints[i] = constraints[last]
+			constraints[last] = nil
+			return constraints[:last]
+		}
+	}
+	return constraints
+}
@@ -0,0 +1,309 @@
+package chipmunk
+
+import (
+	"math"
+	"time"
+)
+
+// Spatial index that hashes the bounding boxes of objects into a grid of square cells.
+// Works best when the objects are roughly the same size and the cells are about as big as them.
+// The grid is infinite, cells are mapped onto a fixed number of buckets.
+type SpaceHash struct {
+	SpatialIndex *SpatialIndex
+
+	handles map[HashValue]*hashHandle
+	// The handles in insertion order, iterated instead of the map so the
+	// order of the pairs and of the collisions is deterministic.
+	handleList []*hashHandle
+
+	celldim float32
+	table   [][]*hashHandle
+
+	// Incremented for every object queried to not report an object twice
+	// when it is found in several cells.
+	stamp time.Duration
+}
+
+type hashHandle struct {
+	// Passed to Each(), holds the object.
+	node Node
+	// Bounding box the object was hashed with.
+	bb    AABB
+	stamp time.Duration
+	// Position of the handle in SpaceHash.handleList.
+	index int
+}
+
+// Creates a spatial hash with cells of celldim size mapped onto numcells buckets.
+// numcells should be a few times larger than the number of objects.
+func NewSpaceHash(celldim float32, numcells int, staticIndex *SpatialIndex) *SpatialIndex {
+	hash := &SpaceHash{}
+	hash.handles = make(map[HashValue]*hashHandle)
+	hash.stamp = 1
+	hash.Resize(celldim, numcells)
+
+	hash.SpatialIndex = NewSpartialIndex(hash, staticIndex)
+	return hash.SpatialIndex
+}
+
+// Changes the size of the cells and the number of buckets and rehashes all objects.
+func (hash *SpaceHash) Resize(celldim float32, numcells int) {
+	if numcells < 1 {
+		numcells = 1
+	}
+	hash.celldim = celldim
+	hash.table = make([][]*hashHandle, numcells)
+	hash.Reindex()
+}
+
+func (hash *SpaceHash) Destroy() {
+	hash.handles = make(map[HashValue]*hashHandle)
+	hash.handleList = nil
+	hash.clearTable()
+}
+
+func (hash *SpaceHash) Count() int {
+	return len(hash.handles)
+}
+
+func (hash *SpaceHash) Each(fnc HashSetIterator) {
+	for _, handle := range hash.handleList {
+		fnc(&handle.node)
+	}
+}
+
+func (hash *SpaceHash) Contains(obj Indexable) bool {
+	_, ok := hash.handles[obj.Hash()]
+	return ok
+}
+
+func (hash *SpaceHash) Insert(obj Indexable) {
+	if hash.Contains(obj) {
+		return
+	}
+
+	handle := &hashHandle{node: Node{obj: obj}}
+	hash.handles[obj.Hash()] = handle
+	handle.index = len(hash.handleList)
+	hash.handleList = append(hash.handleList, handle)
+
+	hash.hashHandle(handle, obj.AABB())
+}
+
+func (hash *SpaceHash) Remove(obj Indexable) {
+	handle := hash.handles[obj.Hash()]
+	if handle == nil {
+		return
+	}
+	delete(hash.handles, obj.Hash())
+
+	last := len(hash.handleList) - 1
+	moved := hash.handleList[last]
+	hash.handleList[handle.index] = moved
+	moved.index = handle.index
+	hash.handleList[last] = nil
+	hash.handleList = hash.handleList[:last]
+
+	hash.unhashHandle(handle)
+}
+
+func (hash *SpaceHash) Reindex() {
+	hash.clearTable()
+	for _, handle := range hash.handleList {
+		hash.hashHandle(handle, handle.node.obj.AABB())
+	}
+}
+
+func (hash *SpaceHash) ReindexObject(obj Indexable) {
+	handle := hash.handles[obj.Hash()]
+	if handle == nil {
+		return
+	}
+	hash.unhashHandle(handle)
+	hash.hashHandle(handle, obj.AABB())
+}
+
+// Rehashes every object and reports every pair of objects with overlapping bounding boxes once,
+// then collides the objects with the static index.
+func (hash *SpaceHash) ReindexQuery(fnc SpatialIndexQueryFunc) {
+	hash.clearTable()
+
+	// Query every object against the ones hashed before it, then hash it.
+	for _, handle := range hash.handleList {
+		handle.bb = handle.node.obj.AABB()
+		hash.eachCell(handle.bb, func(idx int) {
+			bin := hash.table[idx]
+			if containsHandle(bin, handle) {
+				return
+			}
+			hash.queryBin(bin, handle.node.obj, handle.bb, fnc)
+			hash.table[idx] = append(bin, handle)
+		})
+		hash.stamp++
+	}
+
+	if hash.SpatialIndex.staticIndex != nil {
+		SpatialIndexCollideStatic(hash, hash.SpatialIndex.staticIndex, fnc)
+	}
+}
+
+func (hash *SpaceHash) Stamp() time.Duration {
+	return hash.stamp
+}
+
+func (hash *SpaceHash) Query(obj Indexable, aabb AABB, fnc SpatialIndexQueryFunc) {
+	hash.eachCell(aabb, func(idx int) {
+		hash.queryBin(hash.table[idx], obj, aabb, fnc)
+	})
+	hash.stamp++
+}
+
+// Walks the cells along the segment in order and stops at the alpha returned by fnc.
+func (hash *SpaceHash) SegmentQuery(obj Indexable, a, b Vect, t_exit float32, fnc SpatialIndexSegmentQueryFunc) {
+	a = Mult(a, 1/hash.celldim)
+	b = Mult(b, 1/hash.celldim)
+
+	cellX, cellY := floorInt(a.X), floorInt(a.Y)
+
+	var t float32
+	var xInc, yInc int
+	var tempV, tempH float32
+
+	if b.X > a.X {
+		xInc = 1
+		tempH = float32(math.Floor(float64(a.X+1))) - a.X
+	} else {
+		xInc = -1
+		tempH = a.X - float32(math.Floor(float64(a.X)))
+	}
+
+	if b.Y > a.Y {
+		yInc = 1
+		tempV = float32(math.Floor(float64(a.Y+1))) - a.Y
+	} else {
+		yInc = -1
+		tempV = a.Y - float32(math.Floor(float64(a.Y)))
+	}
+
+	dx, dy := FAbs(b.X-a.X), FAbs(b.Y-a.Y)
+	dtdx, dtdy := Inf, Inf
+	if dx != 0 {
+		dtdx = 1 / dx
+	}
+	if dy != 0 {
+		dtdy = 1 / dy
+	}
+
+	nextH, nextV := dtdx, dtdy
+	if tempH != 0 {
+		nextH = tempH * dtdx
+	}
+	if tempV != 0 {
+		nextV = tempV * dtdy
+	}
+
+	for t < t_exit {
+		bin := hash.table[hash.hashCell(cellX, cellY)]
+		for _, handle := range bin {
+			if handle.stamp == hash.stamp {
+				continue
+			}
+			handle.stamp = hash.stamp
+			t_exit = FMin(t_exit, fnc(obj, handle.node.obj))
+		}
+
+		if nextV < nextH {
+			cellY += yInc
+			t = nextV
+			nextV += dtdy
+		} else {
+			cellX += xInc
+			t = nextH
+			nextH += dtdx
+		}
+	}
+
+	hash.stamp++
+}
+
+// Reports the objects in bin overlapping aabb that weren't reported yet for the current stamp.
+func (hash *SpaceHash) queryBin(bin []*hashHandle, obj Indexable, aabb AABB, fnc SpatialIndexQueryFunc) {
+	for _, handle := range bin {
+		other := handle.node.obj
+		if handle.stamp == hash.stamp || other == obj {
+			continue
+		}
+		handle.stamp = hash.stamp
+		if TestOverlap(handle.bb, aabb) {
+			fnc(obj, other)
+		}
+	}
+}
+
+func (hash *SpaceHash) hashHandle(handle *hashHandle, bb AABB) {
+	handle.bb = bb
+	hash.eachCell(bb, func(idx int) {
+		if !containsHandle(hash.table[idx], handle) {
+			hash.table[idx] = append(hash.table[idx], handle)
+		}
+	})
+}
+
+func (hash *SpaceHash) unhashHandle(handle *hashHandle) {
+	hash.eachCell(handle.bb, func(idx int) {
+		bin := hash.table[idx]
+		for i, other := range bin {
+			if other == handle {
+				copy(bin[i:], bin[i+1:])
+				bin[len(bin)-1] = nil
+				hash.table[idx] = bin[:len(bin)-1]
+				return
+			}
+		}
+	})
+}
+
+func (hash *SpaceHash) clearTable() {
+	for i, bin := range hash.table {
+		for j := range bin {
+			bin[j] = nil
+		}
+		hash.table[i] = bin[:0]
+	}
+}
+
+// Calls fnc with the bucket of every cell covered by bb.
+func (hash *SpaceHash) eachCell(bb AABB, fnc func(idx int)) {
+	dim := hash.celldim
+	l := floorInt(bb.Lower.X / dim)
+	r := floorInt(bb.Upper.X / dim)
+	b := floorInt(bb.Lower.Y / dim)
+	t := floorInt(bb.Upper.Y / dim)
+
+	for i := l; i <= r; i++ {
+		for j := b; j <= t; j++ {
+			fnc(hash.hashCell(i, j))
+		}
+	}
+}
+
+func (hash *SpaceHash) hashCell(x, y int) int {
+	return int((uint32(x)*1640531513 ^ uint32(y)*2654435789) % uint32(len(hash.table)))
+}
+
+func containsHandle(bin []*hashHandle, handle *hashHandle) bool {
+	for _, other := range bin {
+		if other == handle {
+			return true
+		}
+	}
+	return false
+}
+
+func floorInt(f float32) int {
+	i := int(f)
+	if f < 0 && float32(i) != f {
+		return i - 1
+	}
+	return i
+}
@@ -0,0 +1,498 @@
+package chipmunk
+
+import (
+	"math"
+	"testing"
+)
+
+func stepSpace(space *Space, steps int) {
+	for i := 0; i < steps; i++ {
+		space.Step(1.0 / 60.0)
+	}
+}
+
+// Returns a space with gravity and a static 100x10 platform centered at the origin.
+func newGroundSpace() (space *Space, ground *Body) {
+	space = NewSpace()
+	space.Gravity = Vect{0, -600}
+	ground = NewBodyStatic()
+	ground.AddShape(NewBox(Vector_Zero, 100, 10))
+	space.AddBody(ground)
+	return
+}
+
+// Returns a box of 10x10 resting on the platform of newGroundSpace() at x, level boxes up.
+func addRestingBox(space *Space, x float32, level int) *Body {
+	box := NewBody(1, (10*10+10*10)/12.0)
+	box.AddShape(NewBox(Vector_Zero, 10, 10))
+	box.SetPosition(Vect{x, 10 + 10*float32(level)})
+	space.AddBody(box)
+	return box
+}
+
+// Steps the space until the body falls asleep, at most for the number of steps.
+func stepUntilSleeping(space *Space, body *Body, steps int) bool {
+	for i := 0; i < steps && !body.IsSleeping(); i++ {
+		space.Step(1.0 / 60.0)
+	}
+	return body.IsSleeping()
+}
+
+func TestSpaceSleepingStack(t *testing.T) {
+	space, ground := newGroundSpace()
+	space.SetSleepTimeThreshold(0.5)
+	var stack []*Body
+	for i := 0; i < 3; i++ {
+		stack = append(stack, addRestingBox(space, 0, i))
+	}
+	sleeping := func() (n int) {
+		for _, box := range stack {
+			if box.IsSleeping() {
+				n++
+			}
+		}
+		return n
+	}
+
+	stepSpace(space, 20)
+	if n := sleeping(); n != 0 {
+		t.Fatalf("%d boxes fell asleep before the threshold", n)
+	}
+	if !stepUntilSleeping(space, stack[0], 120) || sleeping() != 3 {
+		t.Fatalf("%d boxes of the resting stack are sleeping", sleeping())
+	}
+	if len(space.sleepingComponents) != 1 || ground.IsSleeping() {
+		t.Fatalf("stack sleeps in %d components", len(space.sleepingComponents))
+	}
+
+	// A ball falling on the stack wakes all of it.
+	ball := NewBody(1, 10)
+	ball.AddShape(NewCircle(Vector_Zero, 5))
+	ball.SetPosition(Vect{0, 50})
+	space.AddBody(ball)
+	for i := 0; i < 60 && stack[0].IsSleeping(); i++ {
+		space.Step(1.0 / 60.0)
+	}
+	if n := sleeping(); n != 0 {
+		t.Fatalf("%d boxes still sleep after the ball hit the stack", n)
+	}
+	space.RemoveBody(ball)
+
+	// An impulse on the top box wakes the boxes below too.
+	if !stepUntilSleeping(space, stack[0], 180) {
+		t.Fatal("stack didn't fall asleep again")
+	}
+	stack[2].AddVelocity(10, 0)
+	if n := sleeping(); n != 0 {
+		t.Fatalf("%d boxes still sleep after an impulse", n)
+	}
+
+	// Removing the top box wakes the boxes it was resting on.
+	if !stepUntilSleeping(space, stack[0], 180) {
+		t.Fatal("stack didn't fall asleep again")
+	}
+	space.RemoveBody(stack[2])
+	if stack[0].IsSleeping() || stack[1].IsSleeping() {
+		t.Fatal("boxes below a removed box still sleep")
+	}
+}
+
+func TestSpaceSleepingIslands(t *testing.T) {
+	space, _ := newGroundSpace()
+	space.SetSleepTimeThreshold(0.5)
+	left := addRestingBox(space, -30, 0)
+	right := addRestingBox(space, 30, 0)
+
+	kinematic := NewBodyKinematic()
+	kinematic.AddShape(NewBox(Vector_Zero, 100, 10))
+	kinematic.SetPosition(Vect{200, 0})
+	space.AddBody(kinematic)
+	carried := addRestingBox(space, 200, 0)
+
+	stepSpace(space, 120)
+	if !left.IsSleeping() || !right.IsSleeping() {
+		t.Fatal("boxes on the static platform didn't fall asleep")
+	}
+	// The static platform doesn't join the boxes into one island.
+	if left.ComponentRoot() == right.ComponentRoot() {
+		t.Fatal("boxes on the same static body share a component")
+	}
+	// Kinematic bodies never sleep and keep the bodies touching them awake.
+	if kinematic.IsSleeping() || carried.IsSleeping() {
+		t.Fatal("kinematic body or the box on it fell asleep")
+	}
+
+	left.AddVelocity(10, 0)
+	if left.IsSleeping() || !right.IsSleeping() {
+		t.Fatal("waking a box didn't wake only its own component")
+	}
+}
+
+func TestSpaceRemoveShapesInLoop(t *testing.T) {
+	space, _, ball := newPlatformSpace(NewBBTree, 0)
+	ball.AddShape(NewCircle(Vect{10, 0}, 5))
+	ball.AddShape(NewCircle(Vect{-10, 0}, 5))
+	space.AddShape(ball.Shapes[1])
+	space.AddShape(ball.Shapes[2])
+
+	for _, shape := range ball.Shapes {
+		space.RemoveShape(shape)
+	}
+	for i, shape := range ball.Shapes {
+		if shape.space != nil {
+			t.Errorf("shape %d wasn't removed", i)
+		}
+	}
+	if len(ball.Shapes) != 3 {
+		t.Errorf("RemoveShape() changed body.Shapes to %d shapes", len(ball.Shapes))
+	}
+	if clone := ball.Clone(); len(clone.Shapes) != 0 {
+		t.Errorf("Clone() copied %d removed shapes", len(clone.Shapes))
+	}
+
+	// The removed shapes stay on the body and must not break the step or the body's removal.
+	stepSpace(space, 10)
+	space.RemoveBody(ball)
+	stepSpace(space, 1)
+	if ball.space != nil {
+		t.Error("body wasn't removed")
+	}
+}
+
+func TestPostStepCallbackKeys(t *testing.T) {
+	space, _, ball := newPlatformSpace(NewBBTree, 0)
+
+	calls := 0
+	fnc := func(space *Space) { calls++ }
+	space.lock()
+	if !space.AddPostStepCallback(ball, fnc) {
+		t.Error("first callback for the key wasn't scheduled")
+	}
+	if space.AddPostStepCallback(ball, fnc) {
+		t.Error("second callback for the same key was scheduled")
+	}
+	if space.AddPostStepCallback([]int{1}, fnc) {
+		t.Error("callback with a slice key was scheduled")
+	}
+	if space.AddPostStepCallback(map[int]int{}, fnc) {
+		t.Error("callback with a map key was scheduled")
+	}
+	space.unlock(true)
+
+	if calls != 1 {
+		t.Errorf("%d callbacks ran, want 1", calls)
+	}
+}
+
+func TestSpaceLockedErrors(t *testing.T) {
+	space, platform, ball := newPlatformSpace(NewBBTree, 0)
+	other := NewBody(1, 1)
+	other.AddShape(NewCircle(Vect{100, 100}, 1))
+	space.AddBody(other)
+
+	newBody := NewBody(1, 1)
+	newShape := NewCircle(Vector_Zero, 1)
+	other.AddShape(newShape)
+	joint := NewPivotJoint(ball, newBody)
+	added := NewPivotJoint(ball, other)
+	space.AddConstraint(added)
+
+	type result struct {
+		name string
+		err  error
+	}
+	var results []result
+	handler := space.AddWildcardHandler(0)
+	handler.Begin = func(arb *Arbiter, space *Space) bool {
+		if results == nil {
+			results = []result{
+				{"TryAddBody", space.TryAddBody(newBody)},
+				{"TryAddShape", space.TryAddShape(newShape)},
+				{"TryAddConstraint", space.TryAddConstraint(joint)},
+				{"TryRemoveConstraint", space.TryRemoveConstraint(added)},
+				{"TryRemoveShape", space.TryRemoveShape(platform.Shapes[0])},
+			}
+		}
+		return true
+	}
+	stepSpace(space, 30)
+
+	if len(results) == 0 {
+		t.Fatal("the begin callback didn't run")
+	}
+	for _, res := range results {
+		if res.err != ErrSpaceLocked {
+			t.Errorf("%s() while locked = %v, want %v", res.name, res.err, ErrSpaceLocked)
+		}
+	}
+	if newBody.space != nil || newShape.space != nil || joint.Constraint().space != nil ||
+		added.Constraint().space != space || platform.Shapes[0].space != space {
+		t.Error("a locked space was changed")
+	}
+}
+
+func TestSpaceAddRemoveErrors(t *testing.T) {
+	space, _, ball := newPlatformSpace(NewBBTree, 0)
+	nanBody := NewBody(1, 1)
+	nanBody.SetPosition(Vect{float32(math.NaN()), 0})
+	zeroMass := NewBody(1, 1)
+	zeroMass.m = 0
+
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"TryAddBody(nil)", space.TryAddBody(nil), ErrNilObject},
+		{"TryAddBody(added)", space.TryAddBody(ball), ErrAlreadyInSpace},
+		{"TryAddBody(NaN position)", space.TryAddBody(nanBody), ErrInvalidPosition},
+		{"TryAddBody(zero mass)", space.TryAddBody(zeroMass), ErrInvalidMass},
+		{"TryAddShape(nil)", space.TryAddShape(nil), ErrNilObject},
+		{"TryAddShape(no body)", space.TryAddShape(NewCircle(Vector_Zero, 1)), ErrNoBody},
+		{"TryAddShape(added)", space.TryAddShape(ball.Shapes[0]), ErrAlreadyInSpace},
+		{"TryAddConstraint(nil)", space.TryAddConstraint(nil), ErrNilObject},
+		{"TryAddConstraint(no body)", space.TryAddConstraint(NewPivotJoint(ball, nil)), ErrNoBody},
+		{"TryRemoveConstraint(not added)", space.TryRemoveConstraint(NewPivotJoint(ball, nanBody)), ErrNotInSpace},
+		{"TryRemoveBody(nil)", space.TryRemoveBody(nil), ErrNilObject},
+		{"TryRemoveBody(not added)", space.TryRemoveBody(nanBody), ErrNotInSpace},
+		{"TryRemoveShape(nil)", space.TryRemoveShape(nil), ErrNilObject},
+		{"TryRemoveShape(not added)", space.TryRemoveShape(NewCircle(Vector_Zero, 1)), ErrNotInSpace},
+	}
+	for _, test := range tests {
+		if test.err != test.want {
+			t.Errorf("%s = %v, want %v", test.name, test.err, test.want)
+		}
+	}
+
+	// A body with a shape in another space isn't added at all.
+	other := NewSpace()
+	shared := NewBody(1, 1)
+	shared.AddShape(NewCircle(Vector_Zero, 1))
+	shared.AddShape(NewCircle(Vect{5, 0}, 1))
+	other.AddShape(shared.Shapes[1])
+	if err := space.TryAddBody(shared); err != ErrAlreadyInSpace {
+		t.Errorf("TryAddBody(shape in another space) = %v, want %v", err, ErrAlreadyInSpace)
+	}
+	if shared.space != nil || shared.Shapes[0].space != nil {
+		t.Error("TryAddBody() added a body with a shape in another space")
+	}
+	nilShape := NewBody(1, 1)
+	nilShape.Shapes = append(nilShape.Shapes, nil)
+	if err := space.TryAddBody(nilShape); err != ErrNilObject {
+		t.Errorf("TryAddBody(nil shape) = %v, want %v", err, ErrNilObject)
+	}
+
+	// The functions without errors do nothing instead.
+	if space.AddBody(ball) != ball || space.AddShape(nil) != nil || space.AddConstraint(nil) != nil {
+		t.Error("add functions didn't return their argument")
+	}
+	space.AddShape(ball.Shapes[0])
+	space.RemoveShape(NewCircle(Vector_Zero, 1))
+	space.RemoveConstraint(NewPivotJoint(ball, nanBody))
+	if len(space.Bodies) != 1 || space.activeShapes.Count() != 1 {
+		t.Errorf("space has %d bodies and %d shapes, want the ball only", len(space.Bodies), space.activeShapes.Count())
+	}
+}
+
+func TestRayCastAll(t *testing.T) {
+	space := NewSpace()
+	wall := NewBodyStatic()
+	wall.AddShape(NewSegment(Vect{40, -10}, Vect{40, 10}, 0))
+	space.AddBody(wall)
+
+	circle := NewBody(1, 1)
+	circle.AddShape(NewCircle(Vector_Zero, 2))
+	circle.SetPosition(Vect{10, 0})
+	space.AddBody(circle)
+
+	box := NewBody(1, 1)
+	box.AddShape(NewBox(Vector_Zero, 4, 4))
+	box.SetPosition(Vect{20, 0})
+	space.AddBody(box)
+
+	sensor := NewBody(1, 1)
+	sensor.AddShape(NewCircle(Vector_Zero, 2))
+	sensor.Shapes[0].IsSensor = true
+	sensor.SetPosition(Vect{30, 0})
+	space.AddBody(sensor)
+
+	hits := space.RayCastAll(Vector_Zero, Vect{50, 0})
+	want := []*Body{circle, box, wall}
+	if len(hits) != len(want) {
+		t.Fatalf("RayCastAll() hit %d shapes, want %d", len(hits), len(want))
+	}
+	for i, hit := range hits {
+		if hit.Body != want[i] {
+			t.Errorf("hit %d is at %v, want the body at %v", i, hit.Point, want[i].Position())
+		}
+	}
+	if !closeTo(hits[0].Point.X, 8) || !closeTo(hits[2].Point.X, 40) {
+		t.Errorf("hit points %v and %v", hits[0].Point, hits[2].Point)
+	}
+
+	found := false
+	space.SegmentQuery(Vector_Zero, Vect{50, 0}, -1, 0, true, func(info *SegmentQueryInfo) {
+		found = found || info.Shape.IsSensor
+	})
+	if !found {
+		t.Error("SegmentQuery() with sensors didn't find the sensor")
+	}
+}
+
+func TestSpaceUpdate(t *testing.T) {
+	space, _, ball := newPlatformSpace(NewBBTree, 0)
+	space.FixedTimestep = 0.25
+
+	// Less than a substep is carried over.
+	if alpha := space.Update(0.125); alpha != 0.5 || space.stamp != 0 {
+		t.Fatalf("Update(0.125) = %v after %d steps, want 0.5 after 0", alpha, space.stamp)
+	}
+	if alpha := space.Update(0.1875); alpha != 0.25 || space.stamp != 1 {
+		t.Fatalf("Update(0.1875) = %v after %d steps, want 0.25 after 1", alpha, space.stamp)
+	}
+	start, end := ball.prevP, ball.p
+	if got := ball.InterpolatedPosition(0.25); got != Lerp(start, end, 0.25) {
+		t.Errorf("InterpolatedPosition(0.25) = %v, want %v", got, Lerp(start, end, 0.25))
+	}
+
+	// Time beyond MaxSubsteps is dropped, keeping the fraction of a substep.
+	space.MaxSubsteps = 2
+	if alpha := space.Update(2.5); alpha != 0.25 || space.stamp != 3 {
+		t.Fatalf("Update(2.5) with 2 substeps = %v after %d steps, want 0.25 after 3", alpha, space.stamp)
+	}
+
+	for _, max := range []int{0, -1} {
+		space.MaxSubsteps = max
+		space.accumulator = 0
+		stamp := space.stamp
+		if alpha := space.Update(2.625); alpha != 0.5 || space.stamp != stamp+10 {
+			t.Fatalf("Update(2.625) with MaxSubsteps %d = %v after %d steps, want 0.5 after 10", max, alpha, space.stamp-stamp)
+		}
+	}
+}
+
+func TestSpaceKinematicBody(t *testing.T) {
+	forEachSpatialIndex(t, func(t *testing.T, newIndex SpatialIndexFunc) {
+		space, _, _ := newPlatformSpace(newIndex, 0)
+
+		// A lift rising through a static post carries a ball up.
+		post := NewBodyStatic()
+		post.AddShape(NewBox(Vect{225, 0}, 10, 200))
+		space.AddBody(post)
+
+		lift := NewBodyKinematic()
+		liftShape := NewBox(Vector_Zero, 60, 10)
+		lift.AddShape(liftShape)
+		lift.SetPosition(Vect{200, -20})
+		lift.SetVelocity(0, 60)
+		space.AddBody(lift)
+
+		ball := NewBody(1, 10)
+		ball.AddShape(NewCircle(Vector_Zero, 5))
+		ball.SetPosition(Vect{200, -5})
+		space.AddBody(ball)
+
+		stepSpace(space, 60)
+		if p := lift.Position(); FAbs(p.X-200) > 0.01 || FAbs(p.Y-40) > 0.01 {
+			t.Fatalf("lift was pushed to %v", p)
+		}
+		if y := ball.Position().Y; y < 45 {
+			t.Fatalf("ball at %v wasn't carried by the lift", y)
+		}
+
+		// Changing the type moves the shapes between the indexes.
+		if err := lift.SetType(BodyType_Static); err != nil {
+			t.Fatal(err)
+		}
+		if !space.staticShapes.Contains(liftShape) || space.activeShapes.Contains(liftShape) {
+			t.Fatal("static lift shape isn't in the static index")
+		}
+		if lift.Velocity() != Vector_Zero {
+			t.Fatalf("static lift moves at %v", lift.Velocity())
+		}
+		if err := lift.SetType(BodyType_Dynamic); err != ErrInvalidMass {
+			t.Fatalf("SetType() of a body without mass returned %v", err)
+		}
+		if err := lift.SetType(BodyType_Kinematic); err != nil {
+			t.Fatal(err)
+		}
+		if space.staticShapes.Contains(liftShape) || !space.activeShapes.Contains(liftShape) {
+			t.Fatal("kinematic lift shape isn't in the active index")
+		}
+
+		// A dynamic body made kinematic keeps its place, and gets its mass back when made dynamic again.
+		if err := ball.SetType(BodyType_Kinematic); err != nil {
+			t.Fatal(err)
+		}
+		p := ball.Position()
+		stepSpace(space, 10)
+		if ball.Position() != p {
+			t.Fatalf("kinematic ball moved from %v to %v", p, ball.Position())
+		}
+		if err := ball.SetType(BodyType_Dynamic); err != nil {
+			t.Fatal(err)
+		}
+		if ball.Mass() != 1 || ball.Moment() != 10 {
+			t.Fatalf("dynamic ball has mass %v and moment %v", ball.Mass(), ball.Moment())
+		}
+	})
+}
+
+func TestSpaceShapeQuery(t *testing.T) {
+	forEachSpatialIndex(t, func(t *testing.T, newIndex SpatialIndexFunc) {
+		space := NewSpaceWithIndex(newIndex)
+
+		ground := NewBodyStatic()
+		ground.AddShape(NewSegment(Vect{-100, -20}, Vect{100, -20}, 1))
+		space.AddBody(ground)
+
+		newBall := func(x float32) *Shape {
+			ball := NewBody(1, 1)
+			shape := NewCircle(Vector_Zero, 10)
+			ball.AddShape(shape)
+			ball.SetPosition(Vect{x, 0})
+			space.AddBody(ball)
+			return shape
+		}
+		ball := newBall(15)
+		sensor := newBall(-15)
+		sensor.IsSensor = true
+		grouped := newBall(0)
+		grouped.Filter.Group = 1
+		newBall(100)
+
+		// Circles sort before boxes, the normals must still point away from the queried shape.
+		for _, probe := range []*Shape{NewCircle(Vector_Zero, 10), NewBox(Vector_Zero, 20, 20)} {
+			NewBodyStatic().AddShape(probe)
+			probe.Filter.Group = 1
+
+			found := make(map[*Shape]*ContactPointSet)
+			if !space.ShapeQuery(probe, func(other *Shape, points *ContactPointSet) {
+				found[other] = points
+			}) {
+				t.Fatal("ShapeQuery() found no collision")
+			}
+
+			if len(found) != 2 || found[ball] == nil || found[sensor] == nil {
+				t.Fatalf("ShapeQuery() found %d shapes", len(found))
+			}
+			for _, points := range found {
+				if points.Count == 0 {
+					t.Fatal("no contact points")
+				}
+			}
+			for _, point := range found[ball].Points[:found[ball].Count] {
+				if point.Normal.X < 0.99 || point.Dist >= 0 {
+					t.Fatalf("contact normal %v with distance %v", point.Normal, point.Dist)
+				}
+			}
+		}
+
+		// A sensor alone doesn't count as a collision.
+		probe := NewCircle(Vect{-25, 0}, 5)
+		NewBodyStatic().AddShape(probe)
+		if space.ShapeQuery(probe, nil) {
+			t.Fatal("ShapeQuery() counted a sensor as a collision")
+		}
+	})
+}
@@ -5,6 +5,10 @@ import (
 )
 
 type SpatialIndexQueryFunc func(a, b Indexable)
+
+// Called for every leaf hit by a segment query. Returns the alpha along the segment
+// at which the query should stop, or 1 to continue to the end.
+type SpatialIndexSegmentQueryFunc func(a, b Indexable) float32
 type ReindexShapesFunc func(a, b *Shape, space *Space)
 type HashSetIterator func(node *Node)
 
@@ -16,7 +20,11 @@ type SpatialIndex struct {
 	staticIndex, dynamicIndex SpatialIndexClass
 }
 
-func SpatialIndexCollideStatic(dynamicIndex, staticIndex *SpatialIndex, fnc SpatialIndexQueryFunc) {
+// Constructor of a spatial index, see NewSpaceWithIndex().
+type SpatialIndexFunc func(staticIndex *SpatialIndex) *SpatialIndex
+
+// Queries every object of the dynamic index against the static index.
+func SpatialIndexCollideStatic(dynamicIndex, staticIndex SpatialIndexClass, fnc SpatialIndexQueryFunc) {
 	if staticIndex.Count() > 0 {
 		dynamicIndex.Each(func(node *Node) {
 			staticIndex.Query(node.obj, node.obj.AABB(), fnc)
@@ -36,12 +44,31 @@ func NewSpartialIndex(class SpatialIndexClass, staticIndex *SpatialIndex) (index
 		if staticIndex.dynamicIndex != nil {
 			panic("This static index is already associated with a dynamic index.")
 		}
-		staticIndex.dynamicIndex = index
+		staticIndex.dynamicIndex = class
 	}
 
 	return
 }
 
+// Implemented by spatial indexes that can insert many objects faster than one by one.
+type spatialIndexBatchInserter interface {
+	insertBatch(objs []Indexable)
+}
+
+// Inserts the objects in the index, in bulk if the index supports it.
+func insertBatch(index *SpatialIndex, objs []Indexable) {
+	if len(objs) == 0 {
+		return
+	}
+	if inserter, ok := index.SpatialIndexClass.(spatialIndexBatchInserter); ok {
+		inserter.insertBatch(objs)
+		return
+	}
+	for _, obj := range objs {
+		index.Insert(obj)
+	}
+}
+
 type Indexable interface {
 	Hashable
 	AABB() AABB
@@ -71,6 +98,7 @@ type SpatialIndexClass interface {
 
 	Stamp() time.Duration
 
+	// Calls fnc with the objects whose bounding box overlaps aabb, except obj itself.
 	Query(obj Indexable, aabb AABB, fnc SpatialIndexQueryFunc)
-	SegmentQuery(obj Indexable, a, b Vect, t_exit float32, fnc func())
+	SegmentQuery(obj Indexable, a, b Vect, t_exit float32, fnc SpatialIndexSegmentQueryFunc)
 }
@@ -0,0 +1,482 @@
+package chipmunk
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// Every spatial index must pass the conformance tests below.
+var spatialIndexes = []struct {
+	name     string
+	newIndex SpatialIndexFunc
+}{
+	{"BBTree", NewBBTree},
+	{"SpaceHash", func(staticIndex *SpatialIndex) *SpatialIndex {
+		return NewSpaceHash(10, 1000, staticIndex)
+	}},
+	{"SweepAndPrune", NewSweepAndPrune},
+}
+
+func forEachSpatialIndex(t *testing.T, fnc func(t *testing.T, newIndex SpatialIndexFunc)) {
+	for _, index := range spatialIndexes {
+		t.Run(index.name, func(t *testing.T) {
+			fnc(t, index.newIndex)
+		})
+	}
+}
+
+// Creates circles of random sizes at random positions in a square of the given size.
+func newIndexTestShapes(rng *rand.Rand, count int, size float32, static bool) []*Shape {
+	shapes := make([]*Shape, count)
+	for i := range shapes {
+		body := NewBody(1, 1)
+		if static {
+			body = NewBodyStatic()
+		}
+		shape := NewCircle(Vector_Zero, 1+rng.Float32()*7)
+		body.AddShape(shape)
+		body.SetPosition(Vect{rng.Float32() * size, rng.Float32() * size})
+		if !static {
+			body.SetVelocity(rng.Float32()*200-100, rng.Float32()*200-100)
+		}
+		shape.Update()
+		shapes[i] = shape
+	}
+	return shapes
+}
+
+func indexContents(index *SpatialIndex) map[*Shape]int {
+	contents := make(map[*Shape]int)
+	index.Each(func(node *Node) {
+		contents[node.obj.Shape()]++
+	})
+	return contents
+}
+
+func randomAABB(rng *rand.Rand, size float32) AABB {
+	l, b := rng.Float32()*size, rng.Float32()*size
+	return NewAABB(l, b, l+rng.Float32()*size/4, b+rng.Float32()*size/4)
+}
+
+func TestSpatialIndexInsertRemove(t *testing.T) {
+	forEachSpatialIndex(t, func(t *testing.T, newIndex SpatialIndexFunc) {
+		index := newIndex(nil)
+		shapes := newIndexTestShapes(rand.New(rand.NewSource(1)), 100, 200, false)
+		for _, shape := range shapes {
+			index.Insert(shape)
+		}
+
+		if index.Count() != len(shapes) {
+			t.Fatalf("Count() = %d, want %d", index.Count(), len(shapes))
+		}
+		contents := indexContents(index)
+		for _, shape := range shapes {
+			if contents[shape] != 1 {
+				t.Fatalf("Each() visited a shape %d times", contents[shape])
+			}
+		}
+
+		removed := shapes[:50]
+		for _, shape := range removed {
+			index.Remove(shape)
+		}
+		// Removing an object that isn't in the index does nothing.
+		index.Remove(removed[0])
+
+		if index.Count() != len(shapes)-len(removed) {
+			t.Fatalf("Count() = %d after removing, want %d", index.Count(), len(shapes)-len(removed))
+		}
+		contents = indexContents(index)
+		for i, shape := range shapes {
+			want := 1
+			if i < len(removed) {
+				want = 0
+			}
+			if contents[shape] != want {
+				t.Fatalf("Each() visited shape %d %d times, want %d", i, contents[shape], want)
+			}
+		}
+
+		index.Query(nil, NewAABB(-1000, -1000, 1000, 1000), func(a, b Indexable) {
+			for _, shape := range removed {
+				if b.Shape() == shape {
+					t.Fatal("Query() reported a removed shape")
+				}
+			}
+		})
+	})
+}
+
+func TestSpatialIndexQuery(t *testing.T) {
+	forEachSpatialIndex(t, func(t *testing.T, newIndex SpatialIndexFunc) {
+		rng := rand.New(rand.NewSource(2))
+		index := newIndex(nil)
+		shapes := newIndexTestShapes(rng, 300, 400, false)
+		for _, shape := range shapes {
+			index.Insert(shape)
+		}
+
+		query := NewCircle(Vector_Zero, 1)
+		for i := 0; i < 100; i++ {
+			aabb := randomAABB(rng, 400)
+
+			found := make(map[*Shape]int)
+			index.Query(query, aabb, func(a, b Indexable) {
+				if a != query {
+					t.Fatal("Query() didn't pass the queried object")
+				}
+				found[b.Shape()]++
+			})
+
+			for _, shape := range shapes {
+				if found[shape] > 1 {
+					t.Fatalf("Query() reported a shape %d times", found[shape])
+				}
+				if TestOverlap(shape.BB, aabb) && found[shape] == 0 {
+					t.Fatalf("Query() missed a shape at %v overlapping %v", shape.BB, aabb)
+				}
+			}
+		}
+
+		// An object in the index never finds itself.
+		for _, shape := range shapes[:50] {
+			index.Query(shape, shape.BB, func(a, b Indexable) {
+				if b == a {
+					t.Fatal("Query() reported the queried object")
+				}
+			})
+		}
+	})
+}
+
+func TestSpatialIndexInterleavedChanges(t *testing.T) {
+	forEachSpatialIndex(t, func(t *testing.T, newIndex SpatialIndexFunc) {
+		rng := rand.New(rand.NewSource(7))
+		index := newIndex(nil)
+		shapes := newIndexTestShapes(rng, 200, 400, false)
+		added := make(map[*Shape]bool)
+
+		// Queries in between the changes must see all of them.
+		for i := 0; i < 1000; i++ {
+			shape := shapes[rng.Intn(len(shapes))]
+			switch {
+			case !added[shape]:
+				index.Insert(shape)
+				added[shape] = true
+			case rng.Intn(2) == 0:
+				index.Remove(shape)
+				delete(added, shape)
+			default:
+				shape.Body.p = Vect{rng.Float32() * 400, rng.Float32() * 400}
+				shape.Update()
+				index.ReindexObject(shape)
+			}
+			if i%3 != 0 {
+				continue
+			}
+
+			aabb := randomAABB(rng, 400)
+			found := make(map[*Shape]int)
+			index.Query(nil, aabb, func(a, b Indexable) {
+				found[b.Shape()]++
+			})
+			for _, shape := range shapes {
+				want := 0
+				if added[shape] && TestOverlap(shape.BB, aabb) {
+					want = 1
+				}
+				// Indexes may report objects that only overlap the loose box they're kept with.
+				if found[shape] > 1 || found[shape] == 1 && !added[shape] || found[shape] < want {
+					t.Fatalf("step %d: Query() reported a shape %d times, want %d", i, found[shape], want)
+				}
+			}
+		}
+
+		if index.Count() != len(added) {
+			t.Fatalf("Count() = %d, want %d", index.Count(), len(added))
+		}
+		contents := indexContents(index)
+		for _, shape := range shapes {
+			if added[shape] && contents[shape] != 1 || !added[shape] && contents[shape] != 0 {
+				t.Fatalf("Each() visited a shape %d times", contents[shape])
+			}
+		}
+	})
+}
+
+func TestSpatialIndexSegmentQuery(t *testing.T) {
+	forEachSpatialIndex(t, func(t *testing.T, newIndex SpatialIndexFunc) {
+		rng := rand.New(rand.NewSource(3))
+		index := newIndex(nil)
+		shapes := newIndexTestShapes(rng, 300, 400, false)
+		for _, shape := range shapes {
+			index.Insert(shape)
+		}
+
+		for i := 0; i < 100; i++ {
+			a := Vect{rng.Float32()*500 - 50, rng.Float32()*500 - 50}
+			b := Vect{rng.Float32()*500 - 50, rng.Float32()*500 - 50}
+
+			found := make(map[*Shape]int)
+			index.SegmentQuery(nil, a, b, 1, func(_, obj Indexable) float32 {
+				found[obj.Shape()]++
+				return 1
+			})
+
+			for _, shape := range shapes {
+				if found[shape] > 1 {
+					t.Fatalf("SegmentQuery() reported a shape %d times", found[shape])
+				}
+				if shape.BB.SegmentQuery(a, b) <= 1 && found[shape] == 0 {
+					t.Fatalf("SegmentQuery() from %v to %v missed a shape at %v", a, b, shape.BB)
+				}
+			}
+		}
+	})
+}
+
+func TestSpatialIndexReindexQuery(t *testing.T) {
+	forEachSpatialIndex(t, func(t *testing.T, newIndex SpatialIndexFunc) {
+		rng := rand.New(rand.NewSource(4))
+		staticIndex := newIndex(nil)
+		index := newIndex(staticIndex)
+
+		shapes := newIndexTestShapes(rng, 200, 300, false)
+		for _, shape := range shapes {
+			index.Insert(shape)
+		}
+		staticShapes := newIndexTestShapes(rng, 50, 300, true)
+		for _, shape := range staticShapes {
+			staticIndex.Insert(shape)
+		}
+
+		static := make(map[*Shape]bool)
+		for _, shape := range staticShapes {
+			static[shape] = true
+		}
+
+		removed := make(map[*Shape]bool)
+
+		const dt = 1.0 / 60.0
+		for step := 0; step < 60; step++ {
+			if step == 30 {
+				for _, shape := range shapes[:50] {
+					index.Remove(shape)
+					removed[shape] = true
+				}
+				shapes = shapes[50:]
+			}
+
+			for _, shape := range shapes {
+				body := shape.Body
+				body.p = Add(body.p, Mult(body.v, dt))
+				shape.Update()
+			}
+
+			type pair struct{ a, b *Shape }
+			found := make(map[pair]int)
+			index.ReindexQuery(func(a, b Indexable) {
+				sa, sb := a.Shape(), b.Shape()
+				if sa == sb {
+					t.Fatal("ReindexQuery() reported a shape with itself")
+				}
+				if static[sa] && static[sb] {
+					t.Fatal("ReindexQuery() reported two static shapes")
+				}
+				// Pairs with static shapes are recorded with the static shape second.
+				if static[sa] {
+					sa, sb = sb, sa
+				}
+				if static[sb] {
+					found[pair{sa, sb}]++
+					return
+				}
+				if sa.Hash() > sb.Hash() {
+					sa, sb = sb, sa
+				}
+				found[pair{sa, sb}]++
+			})
+
+			for p, count := range found {
+				if count > 1 {
+					t.Fatalf("ReindexQuery() reported a pair %d times", count)
+				}
+				if removed[p.a] || removed[p.b] {
+					t.Fatal("ReindexQuery() reported a removed shape")
+				}
+			}
+
+			for i, a := range shapes {
+				for _, b := range shapes[i+1:] {
+					sa, sb := a, b
+					if sa.Hash() > sb.Hash() {
+						sa, sb = sb, sa
+					}
+					if TestOverlap(a.BB, b.BB) && found[pair{sa, sb}] == 0 {
+						t.Fatalf("step %d: ReindexQuery() missed overlapping shapes %v and %v", step, a.BB, b.BB)
+					}
+				}
+				for _, b := range staticShapes {
+					if TestOverlap(a.BB, b.BB) && found[pair{a, b}] == 0 {
+						t.Fatalf("step %d: ReindexQuery() missed a static shape %v overlapping %v", step, b.BB, a.BB)
+					}
+				}
+			}
+		}
+	})
+}
+
+func TestSpatialIndexSpace(t *testing.T) {
+	forEachSpatialIndex(t, func(t *testing.T, newIndex SpatialIndexFunc) {
+		space := NewSpaceWithIndex(newIndex)
+		space.Gravity = Vect{0, -600}
+
+		ground := NewBodyStatic()
+		ground.AddShape(NewSegment(Vect{-400, 0}, Vect{400, 0}, 2))
+		space.AddBody(ground)
+
+		var bodies []*Body
+		for i := 0; i < 20; i++ {
+			body := NewBody(1, 50)
+			body.AddShape(NewBox(Vector_Zero, 10, 10))
+			body.SetPosition(Vect{float32(i%5)*12 - 30, 10 + float32(i/5)*12})
+			space.AddBody(body)
+			bodies = append(bodies, body)
+		}
+
+		stepSpace(space, 120)
+
+		snapshot, err := space.Snapshot()
+		if err != nil {
+			t.Fatal(err)
+		}
+		stepSpace(space, 60)
+		want := bodyStates(bodies)
+
+		if err := space.Restore(snapshot); err != nil {
+			t.Fatal(err)
+		}
+		stepSpace(space, 60)
+		got := bodyStates(bodies)
+
+		for i, body := range bodies {
+			if got[i] != want[i] {
+				t.Fatalf("body %d diverged after restoring: %v, want %v", i, got[i], want[i])
+			}
+			if body.Position().Y < 0 {
+				t.Fatalf("body %d fell through the ground: %v", i, body.Position())
+			}
+		}
+	})
+}
+
+func TestSpatialIndexContainsReindex(t *testing.T) {
+	forEachSpatialIndex(t, func(t *testing.T, newIndex SpatialIndexFunc) {
+		index := newIndex(nil)
+		shapes := newIndexTestShapes(rand.New(rand.NewSource(5)), 100, 200, false)
+		for _, shape := range shapes[:90] {
+			index.Insert(shape)
+		}
+
+		for i, shape := range shapes {
+			if index.Contains(shape) != (i < 90) {
+				t.Fatalf("Contains() = %v for shape %d", index.Contains(shape), i)
+			}
+		}
+		index.Remove(shapes[0])
+		if index.Contains(shapes[0]) {
+			t.Fatal("Contains() = true for a removed shape")
+		}
+		shapes = shapes[1:90]
+
+		// Moves the shapes far away and returns if a query at dest finds them.
+		moved := func(shapes []*Shape, dest AABB) map[*Shape]bool {
+			found := make(map[*Shape]bool)
+			index.Query(nil, dest, func(a, b Indexable) {
+				found[b.Shape()] = true
+			})
+			return found
+		}
+		teleport := func(shapes []*Shape) {
+			for _, shape := range shapes {
+				shape.Body.p.X += 1000
+				shape.Update()
+			}
+		}
+		far := NewAABB(900, -100, 1300, 300)
+
+		teleport(shapes[:20])
+		for _, shape := range shapes[:20] {
+			index.ReindexObject(shape)
+		}
+		found := moved(shapes, far)
+		for i, shape := range shapes[:20] {
+			if !found[shape] {
+				t.Fatalf("shape %d not found at its new position after ReindexObject()", i)
+			}
+		}
+
+		teleport(shapes[20:])
+		index.Reindex()
+		found = moved(shapes, far)
+		for i, shape := range shapes {
+			if !found[shape] {
+				t.Fatalf("shape %d not found at its new position after Reindex()", i)
+			}
+		}
+		if len(moved(shapes, NewAABB(-100, -100, 300, 300))) != 0 {
+			t.Fatal("shapes found at their old position after Reindex()")
+		}
+	})
+}
+
+// Creates a space with a static platform at the origin and a ball above the given position.
+func newPlatformSpace(newIndex SpatialIndexFunc, ballX float32) (space *Space, platform, ball *Body) {
+	space = NewSpaceWithIndex(newIndex)
+	space.Gravity = Vect{0, -600}
+
+	platform = NewBodyStatic()
+	platform.AddShape(NewBox(Vector_Zero, 100, 10))
+	space.AddBody(platform)
+
+	ball = NewBody(1, 10)
+	ball.AddShape(NewCircle(Vector_Zero, 5))
+	ball.SetPosition(Vect{ballX, 20})
+	space.AddBody(ball)
+	return
+}
+
+func TestSpaceReindexStatic(t *testing.T) {
+	forEachSpatialIndex(t, func(t *testing.T, newIndex SpatialIndexFunc) {
+		// Moving the platform away lets the resting ball fall.
+		space, platform, ball := newPlatformSpace(newIndex, 0)
+		stepSpace(space, 60)
+		if y := ball.Position().Y; y < 5 {
+			t.Fatalf("ball fell through the platform: %v", y)
+		}
+
+		platform.SetPosition(Vect{500, 0})
+		if err := space.ReindexShapesForBody(platform); err != nil {
+			t.Fatal(err)
+		}
+		stepSpace(space, 60)
+		if y := ball.Position().Y; y > -100 {
+			t.Fatalf("ball is still at %v after its platform was moved away", y)
+		}
+
+		// Moving the platform under a ball catches it.
+		space, platform, ball = newPlatformSpace(newIndex, 300)
+		platform.SetPosition(Vect{300, 0})
+		if err := space.ReindexStatic(); err != nil {
+			t.Fatal(err)
+		}
+		stepSpace(space, 60)
+		if y := ball.Position().Y; y < 5 {
+			t.Fatalf("ball fell through the moved platform: %v", y)
+		}
+
+		if err := space.ReindexShape(NewCircle(Vector_Zero, 1)); err != ErrNotInSpace {
+			t.Fatalf("ReindexShape() of a shape not in the space returned %v", err)
+		}
+	})
+}
@@ -0,0 +1,229 @@
+package chipmunk
+
+import (
+	"sort"
+	"time"
+)
+
+// Spatial index that keeps the objects sorted by the left side of their bounding box and
+// sweeps along the x axis to find the overlapping pairs.
+// Works best for levels that are much wider than they are tall.
+// Objects move little between steps so the list is kept sorted with an insertion sort.
+type SweepAndPrune struct {
+	SpatialIndex *SpatialIndex
+
+	handles map[HashValue]*sapHandle
+	// The handles sorted by the left side of their bounding box, followed by the unsorted
+	// handles inserted since the last sort. Removed handles stay in it until the next sort.
+	sorted []*sapHandle
+	// Number of handles inserted at the end of sorted and removed from it since the last sort.
+	unsorted, removed int
+
+	// Width of the widest bounding box, queries start this far left of the queried box.
+	maxWidth float32
+
+	stamp time.Duration
+}
+
+type sapHandle struct {
+	// Passed to Each(), holds the object.
+	node Node
+	// Bounding box of the object when it was last sorted.
+	bb AABB
+	// Set when the object is removed, the handle is dropped from SweepAndPrune.sorted on the next sort.
+	removed bool
+}
+
+// Creates a sweep and prune index.
+func NewSweepAndPrune(staticIndex *SpatialIndex) *SpatialIndex {
+	sap := &SweepAndPrune{}
+	sap.handles = make(map[HashValue]*sapHandle)
+
+	sap.SpatialIndex = NewSpartialIndex(sap, staticIndex)
+	return sap.SpatialIndex
+}
+
+func (sap *SweepAndPrune) Destroy() {
+	sap.handles = make(map[HashValue]*sapHandle)
+	sap.sorted = nil
+	sap.unsorted = 0
+	sap.removed = 0
+	sap.maxWidth = 0
+}
+
+func (sap *SweepAndPrune) Count() int {
+	return len(sap.handles)
+}
+
+func (sap *SweepAndPrune) Each(fnc HashSetIterator) {
+	sap.settle()
+	for _, handle := range sap.sorted {
+		if !handle.removed {
+			fnc(&handle.node)
+		}
+	}
+}
+
+func (sap *SweepAndPrune) Contains(obj Indexable) bool {
+	_, ok := sap.handles[obj.Hash()]
+	return ok
+}
+
+func (sap *SweepAndPrune) Insert(obj Indexable) {
+	if sap.Contains(obj) {
+		return
+	}
+
+	sap.insert(obj)
+}
+
+func (sap *SweepAndPrune) Remove(obj Indexable) {
+	handle := sap.handles[obj.Hash()]
+	if handle == nil {
+		return
+	}
+	delete(sap.handles, obj.Hash())
+	handle.removed = true
+	sap.removed++
+}
+
+func (sap *SweepAndPrune) Reindex() {
+	sap.settle()
+	for _, handle := range sap.sorted {
+		handle.bb = handle.node.obj.AABB()
+	}
+	sap.sort()
+}
+
+func (sap *SweepAndPrune) ReindexObject(obj Indexable) {
+	handle := sap.handles[obj.Hash()]
+	if handle == nil {
+		return
+	}
+	// The old handle is dropped with the removed ones, which keeps the others in place.
+	handle.removed = true
+	sap.removed++
+	sap.insert(obj)
+}
+
+// Sorts the objects by their new bounding boxes and reports every pair of objects with
+// overlapping bounding boxes once, then collides the objects with the static index.
+func (sap *SweepAndPrune) ReindexQuery(fnc SpatialIndexQueryFunc) {
+	sap.Reindex()
+
+	for i, handle := range sap.sorted {
+		bb := handle.bb
+		for _, other := range sap.sorted[i+1:] {
+			if other.bb.Lower.X > bb.Upper.X {
+				break
+			}
+			if other.bb.Lower.Y <= bb.Upper.Y && bb.Lower.Y <= other.bb.Upper.Y {
+				fnc(handle.node.obj, other.node.obj)
+			}
+		}
+	}
+
+	if sap.SpatialIndex.staticIndex != nil {
+		SpatialIndexCollideStatic(sap, sap.SpatialIndex.staticIndex, fnc)
+	}
+
+	sap.stamp++
+}
+
+func (sap *SweepAndPrune) Stamp() time.Duration {
+	return sap.stamp
+}
+
+func (sap *SweepAndPrune) Query(obj Indexable, aabb AABB, fnc SpatialIndexQueryFunc) {
+	sap.settle()
+	for _, handle := range sap.sorted[sap.searchLeft(aabb.Lower.X-sap.maxWidth):] {
+		if handle.bb.Lower.X > aabb.Upper.X {
+			break
+		}
+		// Objects removed by fnc are skipped.
+		if !handle.removed && handle.node.obj != obj && TestOverlap(handle.bb, aabb) {
+			fnc(obj, handle.node.obj)
+		}
+	}
+}
+
+func (sap *SweepAndPrune) SegmentQuery(obj Indexable, a, b Vect, t_exit float32, fnc SpatialIndexSegmentQueryFunc) {
+	sap.settle()
+	l, r := FMin(a.X, b.X), FMax(a.X, b.X)
+	for _, handle := range sap.sorted[sap.searchLeft(l-sap.maxWidth):] {
+		if handle.bb.Lower.X > r {
+			break
+		}
+		if !handle.removed && handle.bb.SegmentQuery(a, b) < t_exit {
+			t_exit = FMin(t_exit, fnc(obj, handle.node.obj))
+		}
+	}
+}
+
+// Returns the index of the first handle with its left side at x or to the right of it.
+func (sap *SweepAndPrune) searchLeft(x float32) int {
+	return sort.Search(len(sap.sorted), func(i int) bool {
+		return sap.sorted[i].bb.Lower.X >= x
+	})
+}
+
+// Appends a new handle for the object, it's sorted in on the next query.
+func (sap *SweepAndPrune) insert(obj Indexable) {
+	handle := &sapHandle{node: Node{obj: obj}, bb: obj.AABB()}
+	sap.handles[obj.Hash()] = handle
+	sap.sorted = append(sap.sorted, handle)
+	sap.unsorted++
+
+	sap.maxWidth = FMax(sap.maxWidth, handle.bb.Upper.X-handle.bb.Lower.X)
+}
+
+// Drops the removed handles and merges the inserted ones into the sorted handles.
+// Runs once for all objects inserted and removed since the last query instead of once for each of them.
+func (sap *SweepAndPrune) settle() {
+	if sap.unsorted == 0 && sap.removed == 0 {
+		return
+	}
+
+	// The handles are merged into a new list, a query that inserted or removed objects may still walk the old one.
+	n := len(sap.sorted) - sap.unsorted
+	old, added := sap.sorted[:n], append([]*sapHandle(nil), sap.sorted[n:]...)
+	sort.SliceStable(added, func(i, j int) bool {
+		return added[i].bb.Lower.X < added[j].bb.Lower.X
+	})
+
+	// Inserted handles go after the old ones with the same left side to keep the order stable.
+	merged := make([]*sapHandle, 0, len(sap.sorted)-sap.removed)
+	for len(old) > 0 || len(added) > 0 {
+		var handle *sapHandle
+		if len(old) == 0 || len(added) > 0 && added[0].bb.Lower.X < old[0].bb.Lower.X {
+			handle, added = added[0], added[1:]
+		} else {
+			handle, old = old[0], old[1:]
+		}
+		if !handle.removed {
+			merged = append(merged, handle)
+		}
+	}
+
+	sap.sorted = merged
+	sap.unsorted = 0
+	sap.removed = 0
+}
+
+// Insertion sort, close to linear as the objects barely move between steps.
+func (sap *SweepAndPrune) sort() {
+	sorted := sap.sorted
+	sap.maxWidth = 0
+	for i := 1; i < len(sorted); i++ {
+		handle := sorted[i]
+		j := i
+		for ; j > 0 && sorted[j-1].bb.Lower.X > handle.bb.Lower.X; j-- {
+			sorted[j] = sorted[j-1]
+		}
+		sorted[j] = handle
+	}
+
+	for _, handle := range sorted {
+		sap.maxWidth = FMax(sap.maxWidth, handle.bb.Upper.X-handle.bb.Lower.X)
+	}
+}
@@ -38,6 +38,15 @@ func FClamp(val, min, max float32) float32 {
 	return val
 }
 
+// Returns false for zero, negative numbers and NaN.
+func isPositive(a float32) bool {
+	return a > 0
+}
+
+func isNaN(a float32) bool {
+	return a != a
+}
+
 //basic 2d vector.
 type Vect struct {
 	X, Y float32
@@ -169,6 +178,14 @@ func Normalize(v Vect) Vect {
 	return Vect{v.X * f, v.Y * f}
 }
 
+//returns the normalized input vector or a zero vector if its length is zero.
+func NormalizeSafe(v Vect) Vect {
+	if v.X == 0 && v.Y == 0 {
+		return Vector_Zero
+	}
+	return Normalize(v)
+}
+
 //dot product between two vectors.
 func Dot(v1, v2 Vect) float32 {
 	return (v1.X * v2.X) + (v1.Y * v2.Y)
@@ -207,8 +224,8 @@ func CrossFV(s float32, a Vect) Vect {
 //linear interpolation between two vectors by the given scalar
 func Lerp(v1, v2 Vect, s float32) Vect {
 	return Vect{
-		v1.X + (v1.X-v2.X)*s,
-		v1.Y + (v1.Y-v2.Y)*s,
+		v1.X + (v2.X-v1.X)*s,
+		v1.Y + (v2.Y-v1.Y)*s,
 	}
 }
 
@@ -100,3 +100,26 @@ func TestDist(t *testing.T) {
 		}
 	}
 }
+
+type lerpTest struct {
+	in1, in2 Vect
+	s        float32
+	out      Vect
+}
+
+var lerpTests = []lerpTest{
+	{Vect{0, 0}, Vect{0, 0}, 0.5, Vect{0, 0}},
+	{Vect{1, 2}, Vect{5, 6}, 0, Vect{1, 2}},
+	{Vect{1, 2}, Vect{5, 6}, 1, Vect{5, 6}},
+	{Vect{1, 2}, Vect{5, 6}, 0.5, Vect{3, 4}},
+	{Vect{2, 0}, Vect{0, 4}, 0.25, Vect{1.5, 1}},
+}
+
+func TestLerp(t *testing.T) {
+	for _, at := range lerpTests {
+		v := Lerp(at.in1, at.in2, at.s)
+		if !Equals(at.out, v) {
+			t.Errorf("Lerp(%v, %v, %v) = %v, want %v.", at.in1, at.in2, at.s, v, at.out)
+		}
+	}
+}
@@ -19,3 +19,331 @@ func (verts Vertices) ValidatePolygon() bool {
 
 	return true
 }
+
+// Returns true if the vertices are wound clockwise like polygons need.
+func (verts Vertices) IsClockwise() bool {
+	return verts.signedArea() < 0
+}
+
+// Reverses the vertices if they are wound counter-clockwise.
+func (verts Vertices) ForceClockwise() {
+	if !verts.IsClockwise() {
+		verts.Reverse()
+	}
+}
+
+// Reverses the order of the vertices.
+func (verts Vertices) Reverse() {
+	for i, j := 0, len(verts)-1; i < j; i, j = i+1, j-1 {
+		verts[i], verts[j] = verts[j], verts[i]
+	}
+}
+
+// Returns the area of the outline, negative when it's wound clockwise.
+func (verts Vertices) signedArea() float32 {
+	area := float32(0)
+	for i, v := range verts {
+		area += Cross(v, verts[(i+1)%len(verts)])
+	}
+	return area / 2
+}
+
+// Returns the convex hull of the vertices wound clockwise, starting at the leftmost vertex.
+// Vertices closer than tolerance to the hull are left out.
+// The hull has less than three vertices if the vertices are on a line.
+func (verts Vertices) ConvexHull(tolerance float32) Vertices {
+	if len(verts) == 0 {
+		return nil
+	}
+
+	left, right := verts[0], verts[0]
+	for _, v := range verts[1:] {
+		if v.X < left.X || (v.X == left.X && v.Y < left.Y) {
+			left = v
+		}
+		if v.X > right.X || (v.X == right.X && v.Y > right.Y) {
+			right = v
+		}
+	}
+
+	hull := Vertices{left}
+	if left == right {
+		return hull
+	}
+
+	// Quickhull, the upper half of the hull from left to right and then the lower half back.
+	hull = quickHull(hull, left, right, verticesLeftOf(verts, left, right, tolerance), tolerance)
+	hull = append(hull, right)
+	return quickHull(hull, right, left, verticesLeftOf(verts, right, left, tolerance), tolerance)
+}
+
+// Appends the hull vertices between a and b to hull.
+// verts are the vertices left of the line from a to b.
+func quickHull(hull Vertices, a, b Vect, verts Vertices, tolerance float32) Vertices {
+	if len(verts) == 0 {
+		return hull
+	}
+
+	farthest, max := verts[0], float32(0)
+	for _, v := range verts {
+		if d := Cross(Sub(b, a), Sub(v, a)); d > max {
+			farthest, max = v, d
+		}
+	}
+
+	hull = quickHull(hull, a, farthest, verticesLeftOf(verts, a, farthest, tolerance), tolerance)
+	hull = append(hull, farthest)
+	return quickHull(hull, farthest, b, verticesLeftOf(verts, farthest, b, tolerance), tolerance)
+}
+
+// Returns the vertices farther than tolerance to the left of the line from a to b.
+func verticesLeftOf(verts Vertices, a, b Vect, tolerance float32) (left Vertices) {
+	length := Dist(a, b)
+	for _, v := range verts {
+		if Cross(Sub(b, a), Sub(v, a)) > tolerance*length {
+			left = append(left, v)
+		}
+	}
+	return
+}
+
+// Simplifies the outline with the Douglas-Peucker algorithm.
+// The vertices closer than tolerance to the simplified outline are removed.
+func (verts Vertices) Simplify(tolerance float32) Vertices {
+	if len(verts) < 3 {
+		return append(Vertices(nil), verts...)
+	}
+
+	// The outline is closed, split it in two chains at the vertex farthest from the first one.
+	far := 0
+	for i, v := range verts {
+		if DistSqr(v, verts[0]) > DistSqr(verts[far], verts[0]) {
+			far = i
+		}
+	}
+
+	keep := make([]bool, len(verts))
+	keep[0], keep[far] = true, true
+	simplifyChain(verts, 0, far, tolerance, keep)
+	simplifyChain(verts, far, len(verts), tolerance, keep)
+
+	var simplified Vertices
+	for i, v := range verts {
+		if keep[i] {
+			simplified = append(simplified, v)
+		}
+	}
+	return simplified
+}
+
+// Keeps the vertex between first and last that is farthest from the line between them
+// if it's farther than tolerance, and simplifies the chains on both sides of it.
+// last is len(verts) for the chain that ends at the first vertex.
+func simplifyChain(verts Vertices, first, last int, tolerance float32, keep []bool) {
+	a, b := verts[first], verts[last%len(verts)]
+
+	index, max := -1, tolerance
+	for i := first + 1; i < last; i++ {
+		if d := Dist(verts[i], closestPointOnSegment(a, b, verts[i])); d > max {
+			index, max = i, d
+		}
+	}
+	if index < 0 {
+		return
+	}
+
+	keep[index] = true
+	simplifyChain(verts, first, index, tolerance, keep)
+	simplifyChain(verts, index, last, tolerance, keep)
+}
+
+// Splits a concave outline into convex polygons wound clockwise, ready for NewPolygon().
+// The outline can be wound either way and it can touch itself, but it must not cross itself.
+// It's triangulated by ear clipping and the triangles are merged back into convex polygons
+// with the Hertel-Mehlhorn algorithm, which makes at most four times the optimal number of polygons.
+func (verts Vertices) ConvexDecomposition() []Vertices {
+	outline := verts.cleanOutline()
+	if len(outline) < 3 {
+		return nil
+	}
+	if outline.ValidatePolygon() {
+		return []Vertices{outline}
+	}
+
+	pieces := outline.mergeConvex(outline.triangulate())
+
+	polys := make([]Vertices, len(pieces))
+	for i, piece := range pieces {
+		polys[i] = make(Vertices, len(piece))
+		for j, index := range piece {
+			polys[i][j] = outline[index]
+		}
+	}
+	return polys
+}
+
+// Returns a clockwise copy of the outline without repeated, collinear and spike vertices.
+func (verts Vertices) cleanOutline() Vertices {
+	outline := append(Vertices(nil), verts...)
+	for removed := true; removed; {
+		removed = false
+		for i := 0; i < len(outline) && len(outline) >= 3; {
+			n := len(outline)
+			if isStraight(outline[(i+n-1)%n], outline[i], outline[(i+1)%n]) {
+				outline = append(outline[:i], outline[i+1:]...)
+				removed = true
+			} else {
+				i++
+			}
+		}
+	}
+
+	if len(outline) < 3 {
+		return nil
+	}
+	outline.ForceClockwise()
+	return outline
+}
+
+// Returns true if b is on the line through a and c, including when the outline turns back at b.
+func isStraight(a, b, c Vect) bool {
+	const epsilon = 1e-6
+	ab, bc := Sub(b, a), Sub(c, b)
+	return FAbs(Cross(ab, bc)) <= epsilon*ab.Length()*bc.Length()
+}
+
+// Triangulates the clockwise outline by ear clipping.
+// Returns the triangles as indexes of the outline's vertices.
+func (outline Vertices) triangulate() [][]int {
+	remaining := make([]int, len(outline))
+	for i := range remaining {
+		remaining[i] = i
+	}
+
+	var triangles [][]int
+	for len(remaining) >= 3 {
+		// Clipping ears next to a place where the outline touches itself leaves spikes without area.
+		for i := 0; i < len(remaining) && len(remaining) >= 3; {
+			n := len(remaining)
+			if isStraight(outline[remaining[(i+n-1)%n]], outline[remaining[i]], outline[remaining[(i+1)%n]]) {
+				remaining = append(remaining[:i], remaining[i+1:]...)
+				i = 0
+			} else {
+				i++
+			}
+		}
+		if len(remaining) < 3 {
+			break
+		}
+
+		n := len(remaining)
+		ear := 0
+		for i := range remaining {
+			if outline.isEar(remaining, i) {
+				ear = i
+				break
+			}
+		}
+		// Without an ear, which only happens with rounding errors, the first vertex is clipped anyway.
+
+		triangle := []int{remaining[(ear+n-1)%n], remaining[ear], remaining[(ear+1)%n]}
+		a, b, c := outline[triangle[0]], outline[triangle[1]], outline[triangle[2]]
+		if Cross(Sub(b, a), Sub(c, b)) < 0 {
+			triangles = append(triangles, triangle)
+		}
+		remaining = append(remaining[:ear], remaining[ear+1:]...)
+	}
+	return triangles
+}
+
+// Returns true if the i-th remaining vertex is convex and the triangle it makes with its neighbours
+// is inside the outline.
+func (outline Vertices) isEar(remaining []int, i int) bool {
+	n := len(remaining)
+	triangle := [3]int{remaining[(i+n-1)%n], remaining[i], remaining[(i+1)%n]}
+	a, b, c := outline[triangle[0]], outline[triangle[1]], outline[triangle[2]]
+	if Cross(Sub(b, a), Sub(c, b)) >= 0 {
+		return false
+	}
+
+	for k, index := range remaining {
+		if index == triangle[0] || index == triangle[1] || index == triangle[2] {
+			continue
+		}
+
+		p := outline[index]
+		// Where the outline touches itself, the edges of the other vertex at the same place must not enter the triangle.
+		if p == a || p == b || p == c {
+			for _, neighbour := range [2]int{remaining[(k+n-1)%n], remaining[(k+1)%n]} {
+				if triangleCornerContains(a, b, c, p, Sub(outline[neighbour], p)) {
+					return false
+				}
+			}
+			continue
+		}
+
+		if Cross(Sub(b, a), Sub(p, a)) <= 0 && Cross(Sub(c, b), Sub(p, b)) <= 0 && Cross(Sub(a, c), Sub(p, c)) <= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns true if the direction from the corner of the clockwise triangle a, b, c points into the triangle.
+func triangleCornerContains(a, b, c, corner, dir Vect) bool {
+	prev, next := c, b
+	if corner == b {
+		prev, next = a, c
+	} else if corner == c {
+		prev, next = b, a
+	}
+	return Cross(Sub(corner, prev), dir) < 0 && Cross(Sub(next, corner), dir) < 0
+}
+
+// Merges pieces sharing an edge as long as the merged piece stays convex.
+func (outline Vertices) mergeConvex(pieces [][]int) [][]int {
+	for i := 0; i < len(pieces); i++ {
+		for j := i + 1; j < len(pieces); {
+			if merged := outline.mergePieces(pieces[i], pieces[j]); merged != nil {
+				pieces[i] = merged
+				pieces = append(pieces[:j], pieces[j+1:]...)
+				j = i + 1
+			} else {
+				j++
+			}
+		}
+	}
+	return pieces
+}
+
+// Returns the union of the pieces if they share an edge and the union is convex, nil otherwise.
+func (outline Vertices) mergePieces(p, q []int) []int {
+	for i := range p {
+		a, b := p[i], p[(i+1)%len(p)]
+		for j := range q {
+			if q[j] != b || q[(j+1)%len(q)] != a {
+				continue
+			}
+
+			// Walk p from b to a, then q from a back to b without the shared vertices.
+			merged := make([]int, 0, len(p)+len(q)-2)
+			for k := 1; k <= len(p); k++ {
+				merged = append(merged, p[(i+k)%len(p)])
+			}
+			for k := 2; k < len(q); k++ {
+				merged = append(merged, q[(j+k)%len(q)])
+			}
+
+			for k := range merged {
+				a := outline[merged[k]]
+				b := outline[merged[(k+1)%len(merged)]]
+				c := outline[merged[(k+2)%len(merged)]]
+				if Cross(Sub(b, a), Sub(c, b)) > 0 {
+					return nil
+				}
+			}
+			return merged
+		}
+	}
+	return nil
+}
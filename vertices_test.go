@@ -0,0 +1,116 @@
+package chipmunk
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestVerticesWinding(t *testing.T) {
+	verts := Vertices{{0, 0}, {0, 1}, {1, 1}, {1, 0}}
+	if !verts.IsClockwise() {
+		t.Fatal("clockwise square is not clockwise")
+	}
+
+	verts.Reverse()
+	if verts.IsClockwise() {
+		t.Fatal("counter-clockwise square is clockwise")
+	}
+	verts.ForceClockwise()
+	if !verts.IsClockwise() || !verts.ValidatePolygon() {
+		t.Fatalf("ForceClockwise() returned %v", verts)
+	}
+}
+
+func TestVerticesConvexHull(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	verts := make(Vertices, 200)
+	for i := range verts {
+		verts[i] = Vect{rng.Float32()*100 - 50, rng.Float32()*100 - 50}
+	}
+
+	hull := verts.ConvexHull(0)
+	if len(hull) < 3 || !hull.ValidatePolygon() || !hull.IsClockwise() {
+		t.Fatalf("invalid hull %v", hull)
+	}
+	for i, a := range hull {
+		b := hull[(i+1)%len(hull)]
+		for _, v := range verts {
+			if Cross(Sub(b, a), Sub(v, a)) > 1e-3 {
+				t.Fatalf("%v is outside of the hull", v)
+			}
+		}
+	}
+
+	line := Vertices{{0, 0}, {1, 1}, {2, 2}, {3, 3}}
+	if hull := line.ConvexHull(0); len(hull) != 2 {
+		t.Fatalf("hull of a line is %v", hull)
+	}
+}
+
+func TestVerticesSimplify(t *testing.T) {
+	// A noisy square with extra points on its sides.
+	var verts Vertices
+	corners := Vertices{{0, 0}, {0, 100}, {100, 100}, {100, 0}}
+	rng := rand.New(rand.NewSource(1))
+	for i, a := range corners {
+		b := corners[(i+1)%len(corners)]
+		for j := 0; j < 10; j++ {
+			v := Lerp(a, b, float32(j)/10)
+			if j != 0 {
+				v = Add(v, Vect{rng.Float32() - 0.5, rng.Float32() - 0.5})
+			}
+			verts = append(verts, v)
+		}
+	}
+
+	simplified := verts.Simplify(1)
+	if len(simplified) != 4 {
+		t.Fatalf("simplified to %v", simplified)
+	}
+	for i, v := range simplified {
+		if v != corners[i] {
+			t.Fatalf("simplified to %v", simplified)
+		}
+	}
+}
+
+func TestVerticesConvexDecomposition(t *testing.T) {
+	outlines := map[string]Vertices{
+		"Convex": {{0, 0}, {0, 1}, {1, 1}, {1, 0}},
+		"L":      {{0, 0}, {0, 2}, {1, 2}, {1, 1}, {2, 1}, {2, 0}},
+		// Counter-clockwise, with a collinear and a repeated vertex.
+		"U":    {{0, 0}, {3, 0}, {3, 3}, {2, 3}, {2, 1}, {1, 1}, {1, 3}, {0, 3}, {0, 3}, {0, 1.5}},
+		"Star": {{0, 5}, {1, 1}, {5, 0}, {1, -1}, {0, -5}, {-1, -1}, {-5, 0}, {-1, 1}},
+		"Comb": {{0, 0}, {0, 3}, {1, 3}, {1, 1}, {2, 1}, {2, 3}, {3, 3}, {3, 1}, {4, 1}, {4, 3}, {5, 3}, {5, 0}},
+		// Two squares touching at a corner.
+		"Touching": {{0, 0}, {0, 1}, {1, 1}, {1, 2}, {2, 2}, {2, 1}, {1, 1}, {1, 0}},
+	}
+
+	for name, outline := range outlines {
+		pieces := outline.ConvexDecomposition()
+		if len(pieces) == 0 {
+			t.Fatalf("%s: no pieces", name)
+		}
+
+		area := float32(0)
+		for _, piece := range pieces {
+			if len(piece) < 3 || !piece.ValidatePolygon() || !piece.IsClockwise() {
+				t.Fatalf("%s: invalid piece %v", name, piece)
+			}
+			area += piece.signedArea()
+		}
+		if want := -FAbs(outline.signedArea()); FAbs(area-want) > 1e-4 {
+			t.Fatalf("%s: pieces have an area of %v, want %v", name, area, want)
+		}
+	}
+
+	if pieces := (Vertices{{0, 0}, {0, 2}, {1, 2}, {1, 1}, {2, 1}, {2, 0}}).ConvexDecomposition(); len(pieces) != 2 {
+		t.Fatalf("L split into %d pieces", len(pieces))
+	}
+
+	body := NewBody(1, 1)
+	shapes := NewPolygonsFromOutline(body, outlines["Star"])
+	if len(shapes) == 0 || len(body.Shapes) != len(shapes) {
+		t.Fatalf("NewPolygonsFromOutline() added %d shapes", len(body.Shapes))
+	}
+}